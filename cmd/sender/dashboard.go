@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+const (
+	// dashboardWindow is the rolling window used for RPS and percentiles
+	dashboardWindow = 5 * time.Second
+
+	// dashboardRefresh is how often the dashboard is redrawn on a terminal
+	dashboardRefresh = 250 * time.Millisecond
+
+	// dashboardLogInterval is how often progress is logged when stdout is not a terminal
+	dashboardLogInterval = 5 * time.Second
+
+	// dashboardBarWidth is the width of the progress and per-node bars
+	dashboardBarWidth = 40
+)
+
+// dashboardSample is a completed request kept in the rolling window
+type dashboardSample struct {
+	at      time.Time
+	latency int64
+	failed  bool
+}
+
+// dashboard shows the progress of a run in place using plain ANSI escape
+// codes, or as periodic log lines when the output is not a terminal
+type dashboard struct {
+	logger *slog.Logger
+	out    io.Writer
+	tty    bool
+	total  int
+
+	mu        sync.Mutex
+	start     time.Time
+	completed int
+	failed    int
+	perNode   map[string]int
+	recent    []dashboardSample
+	lines     int
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newDashboard creates a dashboard for a run of total requests written to out
+func newDashboard(logger *slog.Logger, out *os.File, total int) *dashboard {
+	return &dashboard{
+		logger:  logger,
+		out:     out,
+		tty:     isTerminal(out),
+		total:   total,
+		perNode: make(map[string]int),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// isTerminal reports whether the file is a character device such as a TTY
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Observe records a completed request
+func (d *dashboard) Observe(entry sender.TimelineEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.completed++
+	failed := entry.Error != ""
+	if failed {
+		d.failed++
+	} else {
+		d.perNode[entry.Hostname]++
+	}
+
+	d.recent = append(d.recent, dashboardSample{
+		at:      entry.End,
+		latency: entry.End.Sub(entry.Start).Milliseconds(),
+		failed:  failed,
+	})
+}
+
+// Start begins rendering in the background
+func (d *dashboard) Start() {
+	d.start = time.Now()
+
+	interval := dashboardLogInterval
+	if d.tty {
+		interval = dashboardRefresh
+	}
+
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.stop:
+				// Log lines written when the run completed may sit below the
+				// last frame, so the final frame is drawn below them instead
+				// of overwriting them
+				d.mu.Lock()
+				d.lines = 0
+				d.mu.Unlock()
+
+				d.render(time.Now())
+				return
+			case now := <-ticker.C:
+				d.render(now)
+			}
+		}
+	}()
+}
+
+// Stop renders the final state and waits for the renderer to exit,
+// it is safe to call more than once
+func (d *dashboard) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+	})
+}
+
+// Report stops the dashboard so that the final frame is rendered before
+// the reporters that follow it write the results
+func (d *dashboard) Report(*sender.Result) error {
+	d.Stop()
+	return nil
+}
+
+func (d *dashboard) render(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop samples that fell out of the rolling window
+	cutoff := now.Add(-dashboardWindow)
+	i := 0
+	for i < len(d.recent) && d.recent[i].at.Before(cutoff) {
+		i++
+	}
+	d.recent = d.recent[i:]
+
+	stats := windowStats(d.recent, min(now.Sub(d.start), dashboardWindow))
+
+	if !d.tty {
+		d.logger.Info("progress",
+			"completed", d.completed,
+			"total", d.total,
+			"failed", d.failed,
+			"rps", fmt.Sprintf("%.1f", stats.rps),
+			"error_rate", fmt.Sprintf("%.1f%%", stats.errorRate),
+			"p50_ms", stats.p50,
+			"p99_ms", stats.p99,
+			"nodes", len(d.perNode))
+		return
+	}
+
+	var b strings.Builder
+
+	// Move the cursor back to the top of the previous frame
+	if d.lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", d.lines)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Progress: %s %d/%d (%.1f%%) elapsed %s",
+			bar(d.completed, d.total), d.completed, d.total,
+			float64(d.completed)/float64(max(d.total, 1))*100,
+			now.Sub(d.start).Round(100*time.Millisecond)),
+		fmt.Sprintf("RPS: %.1f, Errors: %.1f%% (%d total), p50: %d ms, p99: %d ms",
+			stats.rps, stats.errorRate, d.failed, stats.p50, stats.p99),
+		"",
+	}
+
+	hostnames := make([]string, 0, len(d.perNode))
+	var successful int
+	for hostname, count := range d.perNode {
+		hostnames = append(hostnames, hostname)
+		successful += count
+	}
+	sort.Strings(hostnames)
+
+	for _, hostname := range hostnames {
+		count := d.perNode[hostname]
+		lines = append(lines, fmt.Sprintf("%-20s %s %4d (%.1f%%)",
+			hostname, bar(count, successful), count, float64(count)/float64(successful)*100))
+	}
+
+	for _, line := range lines {
+		// Clear the line before writing so that shorter lines leave no leftovers
+		fmt.Fprintf(&b, "\033[2K%s\n", line)
+	}
+	d.lines = len(lines)
+
+	fmt.Fprint(d.out, b.String())
+}
+
+// dashboardStats are the rates and latencies of the rolling window
+type dashboardStats struct {
+	rps       float64
+	errorRate float64
+	p50       int64
+	p99       int64
+}
+
+// windowStats aggregates the samples completed within window, the error
+// rate is a percentage and the percentiles only cover successful requests
+func windowStats(samples []dashboardSample, window time.Duration) dashboardStats {
+	latencies := make([]int64, 0, len(samples))
+	var failed int
+	for _, s := range samples {
+		if s.failed {
+			failed++
+			continue
+		}
+		latencies = append(latencies, s.latency)
+	}
+
+	var stats dashboardStats
+	if window > 0 {
+		stats.rps = float64(len(samples)) / window.Seconds()
+	}
+	if len(samples) > 0 {
+		stats.errorRate = float64(failed) / float64(len(samples)) * 100
+	}
+	stats.p50 = percentile(latencies, 50)
+	stats.p99 = percentile(latencies, 99)
+	return stats
+}
+
+// bar renders value relative to total as a fixed width bar
+func bar(value, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(value*dashboardBarWidth/total, dashboardBarWidth)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", dashboardBarWidth-filled) + "]"
+}
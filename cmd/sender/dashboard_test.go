@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		p      float64
+		want   int64
+	}{
+		{name: "no values", p: 99, want: 0},
+		{name: "single value", values: []int64{7}, p: 50, want: 7},
+		{name: "median of unsorted values", values: []int64{5, 1, 4, 2, 3}, p: 50, want: 3},
+		{name: "median of an even count", values: []int64{1, 2, 3, 4}, p: 50, want: 2},
+		{name: "p99 of a hundred values", values: sequence(100), p: 99, want: 99},
+		{name: "p99 of ten values", values: sequence(10), p: 99, want: 10},
+		{name: "p0", values: []int64{3, 1, 2}, p: 0, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("percentile() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// sequence returns the values 1 to n
+func sequence(n int) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		values[i] = int64(i + 1)
+	}
+	return values
+}
+
+func TestWindowStats(t *testing.T) {
+	sample := func(latency int64, failed bool) dashboardSample {
+		return dashboardSample{latency: latency, failed: failed}
+	}
+
+	tests := []struct {
+		name    string
+		samples []dashboardSample
+		window  time.Duration
+		want    dashboardStats
+	}{
+		{name: "no samples", window: time.Second, want: dashboardStats{}},
+		{name: "no window", samples: []dashboardSample{sample(10, false)}, want: dashboardStats{p50: 10, p99: 10}},
+		{
+			name:    "successful requests",
+			samples: []dashboardSample{sample(10, false), sample(20, false), sample(30, false), sample(40, false)},
+			window:  2 * time.Second,
+			want:    dashboardStats{rps: 2, p50: 20, p99: 40},
+		},
+		{
+			name:    "failures count towards rate and error rate only",
+			samples: []dashboardSample{sample(10, false), sample(5000, true), sample(30, false), sample(5000, true)},
+			window:  500 * time.Millisecond,
+			want:    dashboardStats{rps: 8, errorRate: 50, p50: 10, p99: 30},
+		},
+		{
+			name:    "only failures",
+			samples: []dashboardSample{sample(1, true), sample(2, true), sample(3, true)},
+			window:  3 * time.Second,
+			want:    dashboardStats{rps: 1, errorRate: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windowStats(tt.samples, tt.window); got != tt.want {
+				t.Errorf("windowStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBar(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  int
+		total  int
+		filled int
+	}{
+		{name: "empty", value: 0, total: 10, filled: 0},
+		{name: "half", value: 5, total: 10, filled: dashboardBarWidth / 2},
+		{name: "full", value: 10, total: 10, filled: dashboardBarWidth},
+		{name: "over total", value: 20, total: 10, filled: dashboardBarWidth},
+		{name: "no total", value: 5, total: 0, filled: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bar(tt.value, tt.total)
+			want := "[" + strings.Repeat("#", tt.filled) + strings.Repeat(".", dashboardBarWidth-tt.filled) + "]"
+			if got != want {
+				t.Errorf("bar(%d, %d) = %q, want %q", tt.value, tt.total, got, want)
+			}
+		})
+	}
+}
+
+func TestDashboard_Render(t *testing.T) {
+	start := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	d := &dashboard{
+		logger:  slog.New(slog.DiscardHandler),
+		out:     &out,
+		tty:     true,
+		total:   10,
+		start:   start,
+		perNode: make(map[string]int),
+	}
+
+	observe := func(ms int, hostname, err string) {
+		end := start.Add(time.Duration(ms) * time.Millisecond)
+		d.Observe(sender.TimelineEntry{Start: end.Add(-20 * time.Millisecond), End: end, Hostname: hostname, Error: err})
+	}
+	observe(100, "node-b", "")
+	observe(200, "node-a", "")
+	observe(300, "node-a", "")
+	observe(400, "", "connection refused")
+
+	d.render(start.Add(2 * time.Second))
+
+	want := []string{
+		"\033[2KProgress: [################........................] 4/10 (40.0%) elapsed 2s",
+		"\033[2KRPS: 2.0, Errors: 25.0% (1 total), p50: 20 ms, p99: 20 ms",
+		"\033[2K",
+		"\033[2Knode-a               [##########################..............]    2 (66.7%)",
+		"\033[2Knode-b               [#############...........................]    1 (33.3%)",
+		"",
+	}
+	if got := out.String(); got != strings.Join(want, "\n") {
+		t.Errorf("first frame =\n%q\nwant\n%q", got, strings.Join(want, "\n"))
+	}
+
+	// The next frame moves the cursor back over the previous one, samples
+	// older than the window no longer count
+	out.Reset()
+	d.render(start.Add(dashboardWindow + time.Second))
+	got := out.String()
+	if !strings.HasPrefix(got, "\033[5A") {
+		t.Errorf("second frame = %q, want it to start by moving up 5 lines", got)
+	}
+	if !strings.Contains(got, "RPS: 0.0, Errors: 0.0% (1 total), p50: 0 ms") {
+		t.Errorf("second frame = %q, want the window emptied", got)
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// Plan is the test a coordinator hands to each of its workers
+type Plan struct {
+	LoadBalancerURL     string             `json:"load_balancer_url"`
+	RequestCount        int                `json:"request_count"`
+	Concurrency         int                `json:"concurrency"`
+	Rate                int                `json:"rate"`
+	Duration            time.Duration      `json:"duration"`
+	Timeout             time.Duration      `json:"timeout"`
+	Endpoints           []sender.Endpoint  `json:"endpoints"`
+	NodeFrom            string             `json:"node_from"`
+	Format              string             `json:"format"`
+	Protocol            string             `json:"protocol"`
+	DisableKeepAlives   bool               `json:"disable_keepalives"`
+	MaxIdleConnsPerHost int                `json:"max_idle_conns_per_host"`
+	InsecureSkipVerify  bool               `json:"insecure_skip_verify"`
+	Retry               sender.RetryPolicy `json:"retry"`
+	SnapshotInterval    time.Duration      `json:"snapshot_interval"`
+}
+
+// Snapshot is streamed by a worker while it runs a plan, it holds the
+// statistics collected so far and the complete results once Final is set
+type Snapshot struct {
+	Final     bool                                `json:"final"`
+	Error     string                              `json:"error,omitempty"`
+	Stats     *sender.LoadBalancerStats           `json:"stats,omitempty"`
+	Latencies map[string]*sender.LatencyHistogram `json:"latencies,omitempty"`
+}
+
+// WorkerResult summarises the part of the test run by a single worker
+type WorkerResult struct {
+	URL            string `json:"url"`
+	TotalRequests  int    `json:"total_requests"`
+	FailedRequests int    `json:"failed_requests"`
+	AvailableNodes int    `json:"available_nodes"`
+	Complete       bool   `json:"complete"`
+}
+
+// DistributedResult holds the merged results of all workers
+type DistributedResult struct {
+	Workers []WorkerResult              `json:"workers"`
+	Overall *sender.LoadBalancerSummary `json:"overall"`
+}
+
+// newSnapshot captures stats, including the per-node latency histograms
+// that are left out of the JSON form of LoadBalancerStats
+func newSnapshot(stats *sender.LoadBalancerStats, final bool) Snapshot {
+	return Snapshot{Final: final, Stats: stats, Latencies: stats.Latencies}
+}
+
+// loadBalancerStats restores the statistics carried by the snapshot
+func (s *Snapshot) loadBalancerStats() *sender.LoadBalancerStats {
+	stats := sender.NewLoadBalancerStats()
+	if s.Stats != nil {
+		stats.Merge(s.Stats)
+		stats.Interrupted = s.Stats.Interrupted
+	}
+	for hostname, histogram := range s.Latencies {
+		stats.Latencies[hostname] = histogram
+	}
+	stats.Finalize()
+	return stats
+}
+
+// plan creates the test plan described by the command line flags
+func (c *SenderConfig) plan() Plan {
+	return Plan{
+		LoadBalancerURL:     c.LoadBalancerURL,
+		RequestCount:        c.RequestCount,
+		Concurrency:         c.Concurrency,
+		Rate:                c.Rate,
+		Duration:            c.Duration,
+		Timeout:             c.Timeout,
+		Endpoints:           c.Endpoints,
+		NodeFrom:            c.NodeFrom,
+		Format:              c.Format,
+		Protocol:            c.Protocol,
+		DisableKeepAlives:   c.DisableKeepAlives,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		InsecureSkipVerify:  c.InsecureSkipVerify,
+		Retry:               c.Retry,
+		SnapshotInterval:    c.SnapshotInterval,
+	}
+}
+
+// config creates the engine configuration a worker runs the plan with
+func (p *Plan) config(logger *slog.Logger, observer sender.Observer) (*sender.Config, error) {
+	extractor, err := sender.ParseNodeExtractor(p.NodeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sender.Config{
+		LoadBalancerURL:     p.LoadBalancerURL,
+		RequestCount:        p.RequestCount,
+		Concurrency:         p.Concurrency,
+		Timeout:             p.Timeout,
+		Endpoints:           p.Endpoints,
+		NodeExtractor:       extractor,
+		Format:              p.Format,
+		Rate:                p.Rate,
+		Duration:            p.Duration,
+		Protocol:            p.Protocol,
+		DisableKeepAlives:   p.DisableKeepAlives,
+		MaxIdleConnsPerHost: p.MaxIdleConnsPerHost,
+		InsecureSkipVerify:  p.InsecureSkipVerify,
+		Retry:               p.Retry,
+		Logger:              logger,
+		Observers:           []sender.Observer{observer},
+	}, nil
+}
+
+// splitPlan divides the requests and the rate of the plan between the
+// workers, every worker runs with the full concurrency and duration
+func splitPlan(plan Plan, workers int) ([]Plan, error) {
+	if plan.RequestCount > 0 && plan.RequestCount < workers {
+		return nil, fmt.Errorf("%d requests cannot be split between %d workers", plan.RequestCount, workers)
+	}
+	if plan.Rate > 0 && plan.Rate < workers {
+		return nil, fmt.Errorf("a rate of %d cannot be split between %d workers", plan.Rate, workers)
+	}
+
+	plans := make([]Plan, workers)
+	for i := range plans {
+		plans[i] = plan
+		plans[i].RequestCount = share(plan.RequestCount, workers, i)
+		plans[i].Rate = share(plan.Rate, workers, i)
+	}
+
+	return plans, nil
+}
+
+// share returns the part of total assigned to worker i, the remainder
+// goes to the first workers
+func share(total, workers, i int) int {
+	part := total / workers
+	if i < total%workers {
+		part++
+	}
+	return part
+}
+
+// progressObserver aggregates completed requests into statistics that can
+// be snapshotted while the run is still going
+type progressObserver struct {
+	mu    sync.Mutex
+	stats *sender.LoadBalancerStats
+}
+
+// Observe records the request
+func (o *progressObserver) Observe(entry sender.TimelineEntry) {
+	var err error
+	if entry.Error != "" {
+		err = errors.New(entry.Error)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats.Record(entry.Hostname, entry.Protocol, entry.End.Sub(entry.Start).Milliseconds(), err)
+}
+
+// snapshot returns a copy of the statistics collected so far
+func (o *progressObserver) snapshot() Snapshot {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stats := sender.NewLoadBalancerStats()
+	stats.Merge(o.stats)
+	return newSnapshot(stats, false)
+}
+
+// worker runs the plans sent by a coordinator, one at a time
+type worker struct {
+	logger *slog.Logger
+	// token is required as bearer token on every plan when set
+	token string
+	busy  atomic.Bool
+}
+
+// newWorkerHandler returns the HTTP handler of a worker
+func newWorkerHandler(logger *slog.Logger, token string) http.Handler {
+	w := &worker{logger: logger, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /plan", w.handlePlan)
+	return mux
+}
+
+// runWorker serves plans on the listen address until ctx is cancelled,
+// a run in progress is then interrupted and its partial results sent
+func runWorker(ctx context.Context, logger *slog.Logger, listen, token string) error {
+	// Anyone who reaches the worker can make it send requests anywhere
+	if token == "" && !isLoopback(listen) {
+		return fmt.Errorf("worker listen address %s is not a loopback address, set a worker token to accept plans from other hosts", listen)
+	}
+
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           newWorkerHandler(logger, token),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("worker listening", "address", listen)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve worker: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down worker: %w", err)
+	}
+	return nil
+}
+
+// isLoopback reports whether the listen address only accepts connections
+// from the same host
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handlePlan runs the plan in the request body and streams snapshots as
+// newline delimited JSON, the last one is marked final
+func (w *worker) handlePlan(rw http.ResponseWriter, r *http.Request) {
+	if w.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(w.token)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, "worker requires the worker token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if !w.busy.CompareAndSwap(false, true) {
+		http.Error(rw, "worker is already running a plan", http.StatusConflict)
+		return
+	}
+	defer w.busy.Store(false)
+
+	var plan Plan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(rw, fmt.Sprintf("failed to decode plan: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	progress := &progressObserver{stats: sender.NewLoadBalancerStats()}
+	logger := w.logger.With("coordinator", r.RemoteAddr)
+	cfg, err := plan.config(logger, progress)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("invalid plan: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	interval := plan.SnapshotInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(rw)
+	encoder := json.NewEncoder(rw)
+	write := func(snapshot Snapshot) bool {
+		if err := encoder.Encode(snapshot); err != nil {
+			logger.Warn("failed to send snapshot", "error", err)
+			return false
+		}
+		if err := controller.Flush(); err != nil {
+			logger.Warn("failed to flush snapshot", "error", err)
+			return false
+		}
+		return true
+	}
+
+	type runResult struct {
+		result *sender.Result
+		err    error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		result, err := sender.Run(r.Context(), cfg)
+		done <- runResult{result: result, err: err}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Keep running when the coordinator is gone, the run ends
+			// together with the request context
+			write(progress.snapshot())
+
+		case res := <-done:
+			if res.err != nil {
+				write(Snapshot{Final: true, Error: res.err.Error()})
+				return
+			}
+			write(newSnapshot(res.result.Stats, true))
+			return
+		}
+	}
+}
+
+// runCoordinator sends a share of the test to every worker, merges the
+// snapshots they stream back and prints the combined results. When ctx is
+// cancelled the latest snapshot of every worker is reported.
+func runCoordinator(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) (*DistributedResult, error) {
+	plans, err := splitPlan(cfg.plan(), len(cfg.Workers))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("starting distributed test",
+		"url", cfg.LoadBalancerURL,
+		"workers", len(cfg.Workers),
+		"requests", cfg.RequestCount,
+		"concurrency_per_worker", cfg.Concurrency)
+
+	startTime := time.Now()
+	latest := make([]Snapshot, len(cfg.Workers))
+	errs := make([]error, len(cfg.Workers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// Snapshots may take as long as the whole run, so there is no client timeout
+	client := &http.Client{}
+
+	for i, workerURL := range cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := streamPlan(ctx, client, workerURL, cfg.WorkerToken, plans[i], func(snapshot Snapshot) {
+				mu.Lock()
+				defer mu.Unlock()
+				latest[i] = snapshot
+			})
+			if err != nil && ctx.Err() == nil {
+				errs[i] = fmt.Errorf("worker %s: %w", workerURL, err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(max(cfg.SnapshotInterval, time.Second))
+	defer ticker.Stop()
+
+wait:
+	for {
+		select {
+		case <-done:
+			break wait
+		case <-ticker.C:
+			mu.Lock()
+			var requests, failed, complete int
+			for _, snapshot := range latest {
+				if snapshot.Stats != nil {
+					requests += snapshot.Stats.TotalRequests
+					failed += snapshot.Stats.FailedRequests
+				}
+				if snapshot.Final {
+					complete++
+				}
+			}
+			mu.Unlock()
+
+			logger.Info("progress", "completed", requests, "failed", failed, "workers_done", complete)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	overall := sender.NewLoadBalancerStats()
+	result := &DistributedResult{Workers: make([]WorkerResult, len(cfg.Workers))}
+	for i, snapshot := range latest {
+		stats := snapshot.loadBalancerStats()
+		overall.Merge(stats)
+		overall.Interrupted = overall.Interrupted || stats.Interrupted || !snapshot.Final
+
+		result.Workers[i] = WorkerResult{
+			URL:            cfg.Workers[i],
+			TotalRequests:  stats.TotalRequests,
+			FailedRequests: stats.FailedRequests,
+			AvailableNodes: stats.AvailableNodes,
+			Complete:       snapshot.Final && !stats.Interrupted,
+		}
+	}
+	overall.Finalize()
+	if cfg.GroupBy != "" {
+		overall.Group(cfg.GroupBy)
+	}
+	result.Overall = overall.Summary()
+
+	logger.Info("distributed test completed",
+		"total_duration", time.Since(startTime),
+		"total_requests", overall.TotalRequests,
+		"successful_requests", overall.SuccessfulReqs,
+		"failed_requests", overall.FailedRequests,
+		"available_nodes", overall.AvailableNodes)
+
+	fmt.Println("\n=== Workers ===")
+	for _, w := range result.Workers {
+		fmt.Printf("%-30s: %5d requests, %4d failed, %2d nodes, complete=%v\n",
+			w.URL, w.TotalRequests, w.FailedRequests, w.AvailableNodes, w.Complete)
+	}
+
+	fmt.Println("\n=== Load Balancer Test Results ===")
+	sender.WriteStats(os.Stdout, overall)
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal distributed results to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+
+	return result, nil
+}
+
+// streamPlan posts the plan to the worker and passes every snapshot it
+// streams back to onSnapshot until the final one arrives
+func streamPlan(ctx context.Context, client *http.Client, workerURL, token string, plan Plan, onSnapshot func(Snapshot)) error {
+	body, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(workerURL, "/")+"/plan", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(msg.String()))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var snapshot Snapshot
+		if err := decoder.Decode(&snapshot); err != nil {
+			return fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+
+		if snapshot.Error != "" {
+			return errors.New(snapshot.Error)
+		}
+
+		onSnapshot(snapshot)
+		if snapshot.Final {
+			return nil
+		}
+	}
+}
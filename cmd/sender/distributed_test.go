@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestSplitPlan(t *testing.T) {
+	plans, err := splitPlan(Plan{RequestCount: 10, Rate: 7, Concurrency: 4}, 3)
+	if err != nil {
+		t.Fatalf("splitPlan() error = %v", err)
+	}
+
+	var requests, rate int
+	for _, plan := range plans {
+		requests += plan.RequestCount
+		rate += plan.Rate
+		if plan.Concurrency != 4 {
+			t.Errorf("Concurrency = %d, want 4", plan.Concurrency)
+		}
+	}
+	if requests != 10 || rate != 7 {
+		t.Errorf("split requests, rate = %d, %d, want 10, 7", requests, rate)
+	}
+	if plans[0].RequestCount != 4 || plans[2].RequestCount != 3 {
+		t.Errorf("RequestCount = %d, %d, want remainder on the first worker", plans[0].RequestCount, plans[2].RequestCount)
+	}
+
+	if _, err := splitPlan(Plan{RequestCount: 2}, 3); err == nil {
+		t.Error("splitPlan() with fewer requests than workers error = nil, want error")
+	}
+}
+
+func TestRunCoordinator(t *testing.T) {
+	var served atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := "node-a"
+		if served.Add(1)%2 == 0 {
+			hostname = "node-b"
+		}
+		_ = json.NewEncoder(w).Encode(api.PingResponse{Message: "pong", Hostname: hostname})
+	}))
+	defer target.Close()
+
+	logger := slog.New(slog.DiscardHandler)
+	workers := []*httptest.Server{
+		httptest.NewServer(newWorkerHandler(logger, "secret")),
+		httptest.NewServer(newWorkerHandler(logger, "secret")),
+	}
+
+	cfg := &SenderConfig{
+		Config: sender.Config{
+			LoadBalancerURL: target.URL,
+			RequestCount:    41,
+			Concurrency:     3,
+			Timeout:         5 * time.Second,
+		},
+		SnapshotInterval: 10 * time.Millisecond,
+		WorkerToken:      "secret",
+	}
+	for _, w := range workers {
+		defer w.Close()
+		cfg.Workers = append(cfg.Workers, w.URL)
+	}
+
+	result, err := runCoordinator(context.Background(), logger, cfg)
+	if err != nil {
+		t.Fatalf("runCoordinator() error = %v", err)
+	}
+
+	if result.Overall.TotalRequests != 41 || result.Overall.SuccessfulReqs != 41 {
+		t.Errorf("TotalRequests, SuccessfulReqs = %d, %d, want 41, 41", result.Overall.TotalRequests, result.Overall.SuccessfulReqs)
+	}
+	if result.Overall.AvailableNodes != 2 || result.Overall.Interrupted {
+		t.Errorf("AvailableNodes, Interrupted = %d, %v, want 2, false", result.Overall.AvailableNodes, result.Overall.Interrupted)
+	}
+	if result.Workers[0].TotalRequests != 21 || result.Workers[1].TotalRequests != 20 {
+		t.Errorf("worker requests = %d, %d, want 21, 20", result.Workers[0].TotalRequests, result.Workers[1].TotalRequests)
+	}
+	for _, w := range result.Workers {
+		if !w.Complete {
+			t.Errorf("worker %s did not complete", w.URL)
+		}
+	}
+}
+
+func TestWorker_Token(t *testing.T) {
+	worker := httptest.NewServer(newWorkerHandler(slog.New(slog.DiscardHandler), "secret"))
+	defer worker.Close()
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "secret", wantStatus: http.StatusUnauthorized},
+		// The invalid plan is only read once the token matched
+		{name: "token", authorization: "Bearer secret", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, worker.URL+"/plan", strings.NewReader("not a plan"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp, err := worker.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   bool
+	}{
+		{listen: ":9200", want: false},
+		{listen: "0.0.0.0:9200", want: false},
+		{listen: "192.0.2.1:9200", want: false},
+		{listen: "127.0.0.1:9200", want: true},
+		{listen: "[::1]:9200", want: true},
+		{listen: "localhost:9200", want: true},
+		{listen: "not an address", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.listen, func(t *testing.T) {
+			if got := isLoopback(tt.listen); got != tt.want {
+				t.Errorf("isLoopback(%q) = %t, want %t", tt.listen, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWorker_RequiresToken(t *testing.T) {
+	err := runWorker(context.Background(), slog.New(slog.DiscardHandler), "0.0.0.0:0", "")
+	if err == nil || !strings.Contains(err.Error(), "set a worker token") {
+		t.Errorf("runWorker() error = %v, want a worker token required", err)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/rpc/pingpb"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// grpcProtocol is reported as the protocol of gRPC calls
+const grpcProtocol = "gRPC"
+
+// GRPCConnection describes the calls sent over a single gRPC connection,
+// an L4 balancer pins every connection to one node
+type GRPCConnection struct {
+	Connection int            `json:"connection"`
+	Requests   int            `json:"requests"`
+	Nodes      map[string]int `json:"nodes"`
+}
+
+// GRPCReport holds the results of a gRPC test run
+type GRPCReport struct {
+	Target        string                      `json:"target"`
+	Health        string                      `json:"health"`
+	Connections   int                         `json:"connections"`
+	Stats         *sender.LoadBalancerSummary `json:"stats"`
+	PerConnection []GRPCConnection            `json:"per_connection"`
+}
+
+// runGRPC load tests the gRPC ping service, the calls are spread round
+// robin over cfg.GRPCConnections connections
+func runGRPC(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	if cfg.Concurrency < 1 {
+		return fmt.Errorf("invalid concurrency: %d (must be at least 1)", cfg.Concurrency)
+	}
+	if cfg.GRPCConnections < 1 {
+		return fmt.Errorf("invalid gRPC connections: %d (must be at least 1)", cfg.GRPCConnections)
+	}
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return err
+	}
+	target, creds, err := grpcTarget(cfg.LoadBalancerURL, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	conns := make([]*grpc.ClientConn, cfg.GRPCConnections)
+	for i := range conns {
+		if conns[i], err = grpc.NewClient(target, grpc.WithTransportCredentials(creds)); err != nil {
+			return fmt.Errorf("failed to create gRPC client: %w", err)
+		}
+		defer conns[i].Close()
+	}
+
+	logger.Info("starting gRPC test",
+		"target", target,
+		"requests", cfg.RequestCount,
+		"concurrency", cfg.Concurrency,
+		"connections", cfg.GRPCConnections)
+
+	report := &GRPCReport{
+		Target:      target,
+		Health:      checkHealth(ctx, conns[0], cfg.Timeout),
+		Connections: cfg.GRPCConnections,
+	}
+	if report.Health != healthpb.HealthCheckResponse_SERVING.String() {
+		logger.Warn("gRPC health check did not report serving", "status", report.Health)
+	}
+
+	requests := make(chan int)
+	go func() {
+		defer close(requests)
+		for reqNum := 1; reqNum <= cfg.RequestCount && ctx.Err() == nil; reqNum++ {
+			select {
+			case requests <- reqNum:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	perConnection := make([]GRPCConnection, cfg.GRPCConnections)
+	for i := range perConnection {
+		perConnection[i] = GRPCConnection{Connection: i + 1, Nodes: make(map[string]int)}
+	}
+	stats := sender.NewLoadBalancerStats()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for reqNum := range requests {
+				conn := (reqNum - 1) % len(conns)
+				client := pingpb.NewPingServiceClient(conns[conn])
+
+				callCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+				reqStart := time.Now()
+				resp, err := client.Ping(callCtx, &pingpb.PingRequest{})
+				reqEnd := time.Now()
+				cancel()
+
+				// Calls aborted by the interruption say nothing about the nodes
+				if ctx.Err() != nil {
+					return
+				}
+
+				entry := sender.TimelineEntry{Request: reqNum, Start: reqStart, End: reqEnd, Hostname: resp.GetHostname(), Protocol: grpcProtocol}
+				if err != nil {
+					err = fmt.Errorf("gRPC call failed: %s", status.Code(err))
+					entry.Error = err.Error()
+				}
+				for _, o := range cfg.Observers {
+					o.Observe(entry)
+				}
+
+				mu.Lock()
+				stats.Record(resp.GetHostname(), grpcProtocol, reqEnd.Sub(reqStart).Milliseconds(), err)
+				perConnection[conn].Requests++
+				if err == nil {
+					perConnection[conn].Nodes[resp.GetHostname()]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stats.Interrupted = ctx.Err() != nil
+	stats.Finalize()
+
+	report.Stats = stats.Summary()
+	report.PerConnection = perConnection
+
+	sender.WriteStats(os.Stdout, stats)
+	displayGRPC(logger, report)
+
+	return nil
+}
+
+// grpcTarget derives the gRPC target and credentials from the URL, https
+// URLs use TLS and http URLs plain HTTP/2
+func grpcTarget(rawURL string, tlsConfig *tls.Config) (string, credentials.TransportCredentials, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", nil, fmt.Errorf("invalid gRPC URL: %s", rawURL)
+	}
+
+	switch u.Scheme {
+	case "http":
+		return u.Host, insecure.NewCredentials(), nil
+	case "https":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		return u.Host, credentials.NewTLS(tlsConfig), nil
+	default:
+		return "", nil, fmt.Errorf("invalid gRPC URL scheme: %s (must be http or https)", u.Scheme)
+	}
+}
+
+// checkHealth asks the standard health service about the whole server
+func checkHealth(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return status.Code(err).String()
+	}
+	return resp.GetStatus().String()
+}
+
+// displayGRPC prints the gRPC results
+func displayGRPC(logger *slog.Logger, report *GRPCReport) {
+	fmt.Println("\n=== gRPC ===")
+	fmt.Printf("Target: %s, Health: %s, Connections: %d\n", report.Target, report.Health, report.Connections)
+
+	fmt.Println("\n=== Nodes Per Connection ===")
+	for _, c := range report.PerConnection {
+		nodes := make([]string, 0, len(c.Nodes))
+		for hostname := range c.Nodes {
+			nodes = append(nodes, hostname)
+		}
+		sort.Strings(nodes)
+
+		fmt.Printf("connection %-5d: %4d requests", c.Connection, c.Requests)
+		for _, hostname := range nodes {
+			fmt.Printf(", %s=%d", hostname, c.Nodes[hostname])
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal gRPC report to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+}
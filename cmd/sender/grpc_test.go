@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGRPCTarget(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		wantTarget   string
+		wantSecurity string
+		wantErr      bool
+	}{
+		{name: "plain", url: "http://localhost:9090", wantTarget: "localhost:9090", wantSecurity: "insecure"},
+		{name: "tls", url: "https://alcatraz.rest:9090", wantTarget: "alcatraz.rest:9090", wantSecurity: "tls"},
+		{name: "unsupported scheme", url: "ws://localhost:9090", wantErr: true},
+		{name: "no host", url: "localhost:9090", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, creds, err := grpcTarget(tt.url, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("grpcTarget(%q) error = nil, want error", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("grpcTarget(%q) error = %v", tt.url, err)
+			}
+			if target != tt.wantTarget {
+				t.Errorf("target = %q, want %q", target, tt.wantTarget)
+			}
+			if got := creds.Info().SecurityProtocol; got != tt.wantSecurity {
+				t.Errorf("security protocol = %q, want %q", got, tt.wantSecurity)
+			}
+		})
+	}
+}
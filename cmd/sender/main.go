@@ -1,48 +1,81 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"sort"
-	"sync"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	api "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
 )
 
-// LoadBalancerStats holds statistics about load balancer distribution
-type LoadBalancerStats struct {
-	AvailableNodes  int                `json:"available_nodes"`
-	TotalRequests   int                `json:"total_requests"`
-	SuccessfulReqs  int                `json:"successful_requests"`
-	FailedRequests  int                `json:"failed_requests"`
-	AverageRespTime int64              `json:"average_response_time_ms"`
-	NodeHostnames   []string           `json:"node_hostnames"`
-	RequestsPerNode map[string]int     `json:"requests_per_node"`
-	ResponseTimes   map[string][]int64 `json:"-"` // Exclude from JSON output
-}
-
-// LoadBalancerSummary holds summary statistics for JSON output (without detailed response times)
-type LoadBalancerSummary struct {
-	AvailableNodes  int            `json:"available_nodes"`
-	TotalRequests   int            `json:"total_requests"`
-	SuccessfulReqs  int            `json:"successful_requests"`
-	FailedRequests  int            `json:"failed_requests"`
-	AverageRespTime int64          `json:"average_response_time_ms"`
-	NodeHostnames   []string       `json:"node_hostnames"`
-	RequestsPerNode map[string]int `json:"requests_per_node"`
-}
-
 // SenderConfig holds configuration for the sender application
 type SenderConfig struct {
-	LoadBalancerURL string
-	RequestCount    int
-	Concurrency     int
-	Timeout         time.Duration
+	sender.Config
+
+	// Watch mode configuration
+	Watch          bool
+	WatchRate      int
+	WatchInterval  time.Duration
+	WatchWindow    time.Duration
+	NodeTimeout    time.Duration
+	ErrorThreshold float64
+
+	// Availability report configuration
+	AvailabilityReport string
+	GapThreshold       time.Duration
+
+	// Live shows a progress dashboard while requests are sent
+	Live bool
+
+	// Scenario is the path to a YAML scenario file describing a staged test
+	Scenario string
+
+	// Targets are compared against each other when there is more than one,
+	// LoadBalancerURL is the URL of the first target
+	Targets     []Target
+	TargetsMode string
+
+	// Sticky session verification, each virtual client keeps its own
+	// cookie jar across StickyRequests sequential requests
+	StickyClients  int
+	StickyRequests int
+
+	// WebSocket test, WSConnections connections to WSPath are held open at
+	// once and exchange WSMessages messages each
+	WSConnections int
+	WSMessages    int
+	WSPath        string
+
+	// GRPC load tests the gRPC ping service at the URL instead of the REST
+	// API, the calls are spread over GRPCConnections connections
+	GRPC            bool
+	GRPCConnections int
+
+	// NodeFrom is the -node-from value, passed on to workers
+	NodeFrom string
+
+	// Distributed mode, a worker runs the plans it receives on Listen and
+	// a coordinator splits the test between the Workers
+	Worker           bool
+	Listen           string
+	Workers          []string
+	SnapshotInterval time.Duration
+	// WorkerToken authenticates the coordinator to its workers, a worker
+	// without one only listens on loopback addresses
+	WorkerToken string
+
+	// Replay is a capture file recorded by the server, its requests are sent
+	// with the original timing divided by ReplaySpeed
+	Replay      string
+	ReplaySpeed float64
 }
 
 var version string
@@ -56,233 +89,471 @@ func main() {
 	logger.Info("starting...", "application", "alcatraz-rest-sender", "version", version)
 
 	// Parse command line flags for sender-specific configuration
-	senderCfg := parseSenderFlags()
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: senderCfg.Timeout,
+	senderCfg, err := parseSenderFlags()
+	if err != nil {
+		logger.Error("invalid command line flags", "error", err)
+		os.Exit(1)
 	}
 
-	// Send requests and collect statistics
-	stats, err := sendRequests(logger, client, senderCfg)
+	// Create HTTP client with timeout and the requested connection model,
+	// shared by all modes
+	senderCfg.Client, err = sender.NewHTTPClient(&senderCfg.Config)
 	if err != nil {
-		logger.Error("failed to send requests", "error", err)
+		logger.Error("failed to create HTTP client", "error", err)
 		os.Exit(1)
 	}
+	senderCfg.Logger = logger
+
+	// Cancel in-flight requests on the first interrupt and keep the partial
+	// results, restoring the default behaviour so a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	exitCode := 0
+
+	// Record a per-request timeline only when an availability report is
+	// requested, watch mode only keeps the most recent requests
+	var timeline *Timeline
+	if senderCfg.AvailabilityReport != "" {
+		limit := 0
+		if senderCfg.Watch {
+			limit = watchTimelineLimit
+		}
+		timeline = NewTimeline(limit)
+		senderCfg.Observers = append(senderCfg.Observers, timeline)
+	}
 
-	// Display results
-	displayResults(logger, stats)
-}
+	switch {
+	case senderCfg.Worker:
+		if err := runWorker(ctx, logger, senderCfg.Listen, senderCfg.WorkerToken); err != nil {
+			logger.Error("worker failed", "error", err)
+			os.Exit(1)
+		}
 
-func parseSenderFlags() *SenderConfig {
-	var (
-		url         = flag.String("url", "http://localhost:8080", "Load balancer URL")
-		reqCount    = flag.Int("requests", 100, "Number of requests to send")
-		concurrency = flag.Int("concurrency", 10, "Number of concurrent requests")
-		timeout     = flag.Duration("timeout", 5*time.Second, "Request timeout")
-		help        = flag.Bool("help", false, "Show help message")
-	)
+	case len(senderCfg.Workers) > 0:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in distributed mode, ignoring -live")
+		}
 
-	flag.Parse()
+		if _, err := runCoordinator(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to run distributed test", "error", err)
+			os.Exit(1)
+		}
 
-	if *help {
-		fmt.Println("Load Balancer Testing Tool")
-		fmt.Println("\nUsage:")
-		flag.PrintDefaults()
-		os.Exit(0)
-	}
+	case senderCfg.Replay != "":
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in replay mode, ignoring -live")
+		}
 
-	return &SenderConfig{
-		LoadBalancerURL: *url,
-		RequestCount:    *reqCount,
-		Concurrency:     *concurrency,
-		Timeout:         *timeout,
-	}
-}
+		if err := runReplay(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to replay requests", "error", err)
+			os.Exit(1)
+		}
 
-func sendRequests(logger *slog.Logger, client *http.Client, cfg *SenderConfig) (*LoadBalancerStats, error) {
-	stats := &LoadBalancerStats{
-		RequestsPerNode: make(map[string]int),
-		ResponseTimes:   make(map[string][]int64),
-	}
+	case len(senderCfg.Targets) > 1:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available with multiple targets, ignoring -live")
+		}
 
-	// Channel to limit concurrency
-	semaphore := make(chan struct{}, cfg.Concurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+		if err := runTargets(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to send requests", "error", err)
+			os.Exit(1)
+		}
 
-	logger.Info("starting load balancer test",
-		"url", cfg.LoadBalancerURL,
-		"requests", cfg.RequestCount,
-		"concurrency", cfg.Concurrency)
+	case senderCfg.StickyClients > 0:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in sticky session mode, ignoring -live")
+		}
 
-	startTime := time.Now()
+		if err := runSticky(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to run sticky session test", "error", err)
+			os.Exit(1)
+		}
 
-	for i := 0; i < cfg.RequestCount; i++ {
-		wg.Add(1)
-		go func(reqNum int) {
-			defer wg.Done()
+	case senderCfg.GRPC:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in gRPC mode, ignoring -live")
+		}
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+		if err := runGRPC(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to run gRPC test", "error", err)
+			os.Exit(1)
+		}
 
-			reqStart := time.Now()
-			resp, err := client.Get(cfg.LoadBalancerURL + "/api/ping")
-			reqDuration := time.Since(reqStart).Milliseconds()
+	case senderCfg.WSConnections > 0:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in WebSocket mode, ignoring -live")
+		}
 
-			mu.Lock()
-			defer mu.Unlock()
+		if err := runWebSocket(ctx, logger, senderCfg); err != nil {
+			logger.Error("failed to run WebSocket test", "error", err)
+			os.Exit(1)
+		}
 
-			stats.TotalRequests++
+	case senderCfg.Scenario != "":
+		if senderCfg.Watch || senderCfg.Live {
+			logger.Warn("watch mode and live dashboard are not available with a scenario, ignoring them")
+		}
 
-			if err != nil {
-				logger.Debug("request failed", "request", reqNum, "error", err)
-				stats.FailedRequests++
-				return
-			}
-			defer resp.Body.Close()
+		passed, err := runScenario(ctx, logger, senderCfg)
+		if err != nil {
+			logger.Error("failed to run scenario", "error", err)
+			os.Exit(1)
+		}
+		if !passed {
+			// Still write the availability report below before failing
+			exitCode = 1
+		}
 
-			if resp.StatusCode != http.StatusOK {
-				logger.Debug("request returned non-200 status",
-					"request", reqNum,
-					"status", resp.StatusCode)
-				stats.FailedRequests++
-				return
-			}
+	case senderCfg.Watch:
+		if senderCfg.Live {
+			logger.Warn("live dashboard is not available in watch mode, ignoring -live")
+		}
 
-			var pingResp api.PingResponse
-			if err := json.NewDecoder(resp.Body).Decode(&pingResp); err != nil {
-				logger.Debug("failed to decode response", "request", reqNum, "error", err)
-				stats.FailedRequests++
-				return
-			}
+		if err := watch(ctx, logger, senderCfg); err != nil {
+			logger.Error("watch failed", "error", err)
+			os.Exit(1)
+		}
 
-			// Update statistics
-			stats.SuccessfulReqs++
-			stats.RequestsPerNode[pingResp.Hostname]++
+	default:
+		// Send requests, collect statistics and display them
+		var dash *dashboard
+		if senderCfg.Live {
+			dash = newDashboard(logger, os.Stdout, senderCfg.RequestCount)
+			senderCfg.Observers = append(senderCfg.Observers, dash)
+			// Render the final frame before the results are printed below it
+			senderCfg.Reporters = append(senderCfg.Reporters, dash)
+			dash.Start()
+		}
 
-			if stats.ResponseTimes[pingResp.Hostname] == nil {
-				stats.ResponseTimes[pingResp.Hostname] = make([]int64, 0)
-			}
-			stats.ResponseTimes[pingResp.Hostname] = append(stats.ResponseTimes[pingResp.Hostname], reqDuration)
+		senderCfg.Reporters = append(senderCfg.Reporters,
+			sender.TextReporter{W: os.Stdout, Title: "Load Balancer Test Results"},
+			// JSON for programmatic use (without detailed response times)
+			sender.JSONReporter{W: os.Stdout, Title: "JSON Output"},
+		)
 
-			logger.Debug("request completed",
-				"request", reqNum,
-				"hostname", pingResp.Hostname,
-				"response_time_ms", reqDuration)
+		_, err := sender.Run(ctx, &senderCfg.Config)
+		if dash != nil {
+			dash.Stop()
+		}
+		if err != nil {
+			logger.Error("failed to send requests", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if timeline != nil {
+		report := buildAvailabilityReport(timeline.Entries(), senderCfg.GapThreshold)
+		report.DroppedRequests = timeline.Dropped()
+		displayAvailability(report)
 
-		}(i + 1)
+		if err := writeAvailabilityReport(report, senderCfg.AvailabilityReport); err != nil {
+			logger.Error("failed to write availability report", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("availability report written", "file", senderCfg.AvailabilityReport)
 	}
 
-	wg.Wait()
-	totalDuration := time.Since(startTime)
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
 
-	// Calculate final statistics
-	finalizeStats(stats)
+// stringsFlag is a command line flag that can be repeated
+type stringsFlag []string
 
-	logger.Info("load balancer test completed",
-		"total_duration", totalDuration,
-		"total_requests", stats.TotalRequests,
-		"successful_requests", stats.SuccessfulReqs,
-		"failed_requests", stats.FailedRequests,
-		"available_nodes", stats.AvailableNodes)
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
 
-	return stats, nil
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
 }
 
-func finalizeStats(stats *LoadBalancerStats) {
-	// Extract unique hostnames and sort them
-	hostnameSet := make(map[string]bool)
-	for hostname := range stats.RequestsPerNode {
-		hostnameSet[hostname] = true
+func parseSenderFlags() (*SenderConfig, error) {
+	var headers stringsFlag
+	flag.Var(&headers, "H", "Request header as \"Name: value\", can be repeated")
+
+	var urls stringsFlag
+	flag.Var(&urls, "url", "Load balancer URL (default http://localhost:8080), repeat as [label=]url to compare several targets")
+
+	var (
+		reqCount    = flag.Int("requests", 100, "Number of requests to send")
+		concurrency = flag.Int("concurrency", 10, "Number of concurrent requests")
+		timeout     = flag.Duration("timeout", 5*time.Second, "Request timeout")
+		help        = flag.Bool("help", false, "Show help message")
+
+		watchMode      = flag.Bool("watch", false, "Continuously send pings and report node changes until interrupted")
+		watchRate      = flag.Int("rate", 10, "Pings per second in watch mode")
+		watchInterval  = flag.Duration("interval", 5*time.Second, "Summary interval in watch mode")
+		watchWindow    = flag.Duration("window", 30*time.Second, "Rolling window for watch mode summaries")
+		nodeTimeout    = flag.Duration("node-timeout", 3*time.Second, "Time without responses after which a node is reported as gone")
+		errorThreshold = flag.Float64("error-threshold", 0.05, "Error rate at which a node is reported as failing")
+
+		availabilityReport = flag.String("availability-report", "", "Write a per-request timeline and node availability report as JSON to this file")
+		gapThreshold       = flag.Duration("gap-threshold", time.Second, "Time without responses from a node that counts as a gap in the availability report")
+
+		live = flag.Bool("live", false, "Show a live progress dashboard, falls back to periodic log lines when stdout is not a terminal")
+
+		scenario = flag.String("scenario", "", "Run the staged test described in this YAML scenario file")
+
+		path     = flag.String("path", sender.DefaultEndpoint.Path, "Request path")
+		method   = flag.String("method", sender.DefaultEndpoint.Method, "Request method")
+		bodyFile = flag.String("body-file", "", "Send the contents of this file as the request body")
+		nodeFrom = flag.String("node-from", "ping", "How to identify the serving node: ping, headers, json:<dotted.path> or header:<name>, HEAD requests use headers instead of ping")
+		groupBy  = flag.String("group-by", "", "Also count the requests per value of this node label, e.g. zone")
+		format   = flag.String("format", "", "Response format to request with the Accept header: "+strings.Join(api.FormatNames(), ", ")+" (default the server default)")
+
+		targetsFile = flag.String("targets-file", "", "Read additional [label=]url targets from this file, one per line")
+		targetsMode = flag.String("targets-mode", TargetsSequential, "Run multiple targets sequential or concurrent")
+		lbTarget    = flag.String("lb-target", "", "Label of the load balancer target (default the first target), the others are direct nodes")
+
+		proto            = flag.String("proto", sender.ProtocolAuto, "HTTP protocol: auto, http1, http2 (TLS ALPN) or h2c (HTTP/2 without TLS)")
+		disableKeepAlive = flag.Bool("disable-keepalive", false, "Open a new connection for every request")
+		maxIdlePerHost   = flag.Int("max-idle-per-host", 0, "Maximum idle connections kept per host (default the Go default of 2)")
+		insecure         = flag.Bool("insecure", false, "Skip TLS certificate verification")
+		certFile         = flag.String("cert", "", "Client certificate presented to nodes that require one, with -key")
+		keyFile          = flag.String("key", "", "Private key of the -cert client certificate")
+		caFile           = flag.String("ca", "", "CA certificate that verifies the nodes instead of the system roots")
+
+		retryAttempts   = flag.Int("retry-attempts", 1, "Maximum attempts per request including the first, 1 disables retries")
+		retryBackoff    = flag.Duration("retry-backoff", 100*time.Millisecond, "Base delay before a retry, doubled for every further retry and jittered")
+		retryMaxBackoff = flag.Duration("retry-max-backoff", 2*time.Second, "Maximum delay before a retry")
+		retryOn         = flag.String("retry-on", "502,503,504", "Comma separated status codes that are retried")
+		retryErrors     = flag.String("retry-errors", sender.RetryErrorTimeout+","+sender.RetryErrorConnection, "Comma separated error classes that are retried: timeout, connection")
+
+		stickyClients  = flag.Int("sticky-clients", 0, "Verify sticky sessions with this many virtual clients, each with its own cookie jar")
+		stickyRequests = flag.Int("sticky-requests", 20, "Sequential requests sent by every virtual client in sticky session mode")
+
+		wsConnections = flag.Int("ws-connections", 0, "Test WebSockets with this many connections held open at once")
+		wsMessages    = flag.Int("ws-messages", 5, "Messages, each preceded by a ping frame, sent on every WebSocket connection")
+		wsPath        = flag.String("ws-path", "/api/v2/ws", "Path of the WebSocket endpoint")
+
+		grpcMode        = flag.Bool("grpc", false, "Load test the gRPC ping service at -url (http for plain HTTP/2, https for TLS)")
+		grpcConnections = flag.Int("grpc-connections", 1, "gRPC connections the calls are spread over round robin")
+
+		workerMode       = flag.Bool("worker", false, "Run as a worker that accepts test plans from a coordinator")
+		listen           = flag.String("listen", "127.0.0.1:9200", "Address a worker listens on")
+		workers          = flag.String("workers", "", "Comma separated worker URLs, splits the test between them and merges the results")
+		snapshotInterval = flag.Duration("snapshot-interval", time.Second, "How often workers send statistics snapshots to the coordinator")
+		workerToken      = flag.String("worker-token", "", "Shared token the coordinator sends and workers require, needed for workers on non-loopback addresses")
+
+		replay      = flag.String("replay", "", "Replay the requests of a capture file recorded by the server")
+		replaySpeed = flag.Float64("replay-speed", 1, "Replay speed relative to the captured timing, 0 sends as fast as possible")
+	)
+
+	flag.Parse()
+
+	if *help {
+		fmt.Println("Load Balancer Testing Tool")
+		fmt.Println("\nUsage:")
+		flag.PrintDefaults()
+		os.Exit(0)
 	}
 
-	stats.NodeHostnames = make([]string, 0, len(hostnameSet))
-	for hostname := range hostnameSet {
-		stats.NodeHostnames = append(stats.NodeHostnames, hostname)
+	endpoint := sender.Endpoint{
+		Method:  strings.ToUpper(*method),
+		Path:    *path,
+		Headers: make(map[string]string),
 	}
-	sort.Strings(stats.NodeHostnames)
 
-	stats.AvailableNodes = len(stats.NodeHostnames)
+	if !strings.HasPrefix(endpoint.Path, "/") {
+		return nil, fmt.Errorf("path %q must start with /", endpoint.Path)
+	}
 
-	// Calculate average response time
-	var totalResponseTime int64
-	var totalResponseCount int64
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q (must be \"Name: value\")", header)
+		}
+		endpoint.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
 
-	for _, responseTimes := range stats.ResponseTimes {
-		for _, responseTime := range responseTimes {
-			totalResponseTime += responseTime
-			totalResponseCount++
+	if *bodyFile != "" {
+		body, err := os.ReadFile(*bodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body file %s: %w", *bodyFile, err)
 		}
+		endpoint.Body = string(body)
 	}
 
-	if totalResponseCount > 0 {
-		stats.AverageRespTime = totalResponseTime / totalResponseCount
+	// HEAD responses have no body to decode the ping response from
+	if endpoint.Method == http.MethodHead && *nodeFrom == "ping" {
+		*nodeFrom = "headers"
+	}
+	extractor, err := sender.ParseNodeExtractor(*nodeFrom)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := api.FormatByName(*format); *format != "" && !ok {
+		return nil, fmt.Errorf("invalid format: %s (must be one of %s)", *format, strings.Join(api.FormatNames(), ", "))
 	}
-}
 
-func displayResults(logger *slog.Logger, stats *LoadBalancerStats) {
-	fmt.Println("\n=== Load Balancer Test Results ===")
-	fmt.Printf("Total Requests: %d\n", stats.TotalRequests)
-	fmt.Printf("Successful Requests: %d\n", stats.SuccessfulReqs)
-	fmt.Printf("Failed Requests: %d\n", stats.FailedRequests)
-	fmt.Printf("Available Nodes: %d\n", stats.AvailableNodes)
-	fmt.Printf("Average Response Time: %d ms\n\n", stats.AverageRespTime)
+	targets, err := parseTargets(urls, *targetsFile, *lbTarget)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println("=== Node Hostnames ===")
-	for i, hostname := range stats.NodeHostnames {
-		fmt.Printf("%d. %s\n", i+1, hostname)
+	if len(targets) > 1 && (*watchMode || *scenario != "") {
+		return nil, fmt.Errorf("multiple targets cannot be combined with watch mode or a scenario")
+	}
+	var workerURLs []string
+	for _, value := range strings.Split(*workers, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			workerURLs = append(workerURLs, value)
+		}
+	}
+	if len(workerURLs) > 0 && (len(targets) > 1 || *watchMode || *scenario != "" || *stickyClients > 0) {
+		return nil, fmt.Errorf("distributed mode cannot be combined with multiple targets, watch mode, a scenario or sticky sessions")
+	}
+	if len(workerURLs) > 0 && *availabilityReport != "" {
+		return nil, fmt.Errorf("the availability report is not available in distributed mode")
+	}
+
+	if *replay != "" && (len(targets) > 1 || *watchMode || *scenario != "" || *stickyClients > 0 || len(workerURLs) > 0) {
+		return nil, fmt.Errorf("replay cannot be combined with multiple targets, watch mode, a scenario, sticky sessions or distributed mode")
+	}
+
+	if *stickyClients > 0 && (len(targets) > 1 || *watchMode || *scenario != "") {
+		return nil, fmt.Errorf("sticky session mode cannot be combined with multiple targets, watch mode or a scenario")
+	}
+	if *wsConnections > 0 && (len(targets) > 1 || *watchMode || *scenario != "" || *stickyClients > 0 || len(workerURLs) > 0 || *replay != "") {
+		return nil, fmt.Errorf("WebSocket mode cannot be combined with multiple targets, watch mode, a scenario, sticky sessions, distributed mode or replay")
+	}
+	if *grpcMode && (len(targets) > 1 || *watchMode || *scenario != "" || *stickyClients > 0 || len(workerURLs) > 0 || *replay != "" || *wsConnections > 0) {
+		return nil, fmt.Errorf("gRPC mode cannot be combined with multiple targets, watch mode, a scenario, sticky sessions, distributed mode, replay or WebSocket mode")
+	}
+	if *groupBy != "" && (*watchMode || *stickyClients > 0 || *replay != "" || *wsConnections > 0 || *grpcMode) {
+		return nil, fmt.Errorf("grouping by a node label cannot be combined with watch mode, sticky sessions, replay, WebSocket mode or gRPC mode")
+	}
+	// The timeline does not tell targets or virtual clients apart and
+	// WebSocket messages are not recorded at all
+	if *availabilityReport != "" && (len(targets) > 1 || *stickyClients > 0 || *wsConnections > 0) {
+		return nil, fmt.Errorf("the availability report cannot be combined with multiple targets, sticky sessions or WebSocket mode")
+	}
+	if !strings.HasPrefix(*wsPath, "/") {
+		return nil, fmt.Errorf("WebSocket path %q must start with /", *wsPath)
+	}
+	retry, err := sender.ParseRetryPolicy(*retryAttempts, *retryBackoff, *retryMaxBackoff, *retryOn, *retryErrors)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Println("\n=== Requests Per Node ===")
-	for _, hostname := range stats.NodeHostnames {
-		count := stats.RequestsPerNode[hostname]
-		percentage := float64(count) / float64(stats.SuccessfulReqs) * 100
-		fmt.Printf("%-20s: %4d requests (%.1f%%)\n", hostname, count, percentage)
+	if *targetsMode != TargetsSequential && *targetsMode != TargetsConcurrent {
+		return nil, fmt.Errorf("invalid targets mode: %s (must be %s or %s)", *targetsMode, TargetsSequential, TargetsConcurrent)
 	}
 
-	fmt.Println("\n=== Response Time Statistics (ms) ===")
-	for _, hostname := range stats.NodeHostnames {
-		responseTimes := stats.ResponseTimes[hostname]
-		if len(responseTimes) == 0 {
-			continue
+	return &SenderConfig{
+		Config: sender.Config{
+			LoadBalancerURL: targets[0].URL,
+			RequestCount:    *reqCount,
+			Concurrency:     *concurrency,
+			Timeout:         *timeout,
+
+			Endpoints:     []sender.Endpoint{endpoint},
+			NodeExtractor: extractor,
+			Format:        *format,
+			GroupBy:       *groupBy,
+
+			Protocol:            *proto,
+			DisableKeepAlives:   *disableKeepAlive,
+			MaxIdleConnsPerHost: *maxIdlePerHost,
+			InsecureSkipVerify:  *insecure,
+			ClientCertFile:      *certFile,
+			ClientKeyFile:       *keyFile,
+			CAFile:              *caFile,
+
+			Retry: retry,
+		},
+
+		Watch:          *watchMode,
+		WatchRate:      *watchRate,
+		WatchInterval:  *watchInterval,
+		WatchWindow:    *watchWindow,
+		NodeTimeout:    *nodeTimeout,
+		ErrorThreshold: *errorThreshold,
+
+		AvailabilityReport: *availabilityReport,
+		GapThreshold:       *gapThreshold,
+
+		Live: *live,
+
+		Scenario: *scenario,
+
+		Targets:     targets,
+		TargetsMode: *targetsMode,
+
+		StickyClients:  *stickyClients,
+		StickyRequests: *stickyRequests,
+
+		WSConnections: *wsConnections,
+		WSMessages:    *wsMessages,
+		WSPath:        *wsPath,
+
+		GRPC:            *grpcMode,
+		GRPCConnections: *grpcConnections,
+
+		NodeFrom: *nodeFrom,
+
+		Worker:           *workerMode,
+		Listen:           *listen,
+		Workers:          workerURLs,
+		SnapshotInterval: *snapshotInterval,
+		WorkerToken:      *workerToken,
+
+		Replay:      *replay,
+		ReplaySpeed: *replaySpeed,
+	}, nil
+}
+
+// parseTargets combines the -url flags and the targets file and marks the
+// load balancer target, the first one unless lbLabel says otherwise
+func parseTargets(urls []string, targetsFile, lbLabel string) ([]Target, error) {
+	targets := make([]Target, 0, len(urls))
+	for _, value := range urls {
+		target, err := parseTarget(value)
+		if err != nil {
+			return nil, err
 		}
+		targets = append(targets, target)
+	}
 
-		var sum, min, max int64
-		min = responseTimes[0]
-		max = responseTimes[0]
-
-		for _, rt := range responseTimes {
-			sum += rt
-			if rt < min {
-				min = rt
-			}
-			if rt > max {
-				max = rt
-			}
+	if targetsFile != "" {
+		fileTargets, err := loadTargetsFile(targetsFile)
+		if err != nil {
+			return nil, err
 		}
+		targets = append(targets, fileTargets...)
+	}
 
-		avg := sum / int64(len(responseTimes))
-		fmt.Printf("%-20s: avg=%3dms, min=%3dms, max=%3dms, count=%d\n",
-			hostname, avg, min, max, len(responseTimes))
+	if len(targets) == 0 {
+		targets = append(targets, Target{Label: "localhost:8080", URL: "http://localhost:8080"})
 	}
 
-	// Output JSON for programmatic use (without detailed response times)
-	fmt.Println("\n=== JSON Output ===")
-	summary := &LoadBalancerSummary{
-		AvailableNodes:  stats.AvailableNodes,
-		TotalRequests:   stats.TotalRequests,
-		SuccessfulReqs:  stats.SuccessfulReqs,
-		FailedRequests:  stats.FailedRequests,
-		AverageRespTime: stats.AverageRespTime,
-		NodeHostnames:   stats.NodeHostnames,
-		RequestsPerNode: stats.RequestsPerNode,
+	labels := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if labels[target.Label] {
+			return nil, fmt.Errorf("duplicate target label: %s", target.Label)
+		}
+		labels[target.Label] = true
 	}
-	jsonOutput, err := json.MarshalIndent(summary, "", "  ")
-	if err != nil {
-		logger.Error("failed to marshal stats to JSON", "error", err)
-	} else {
-		fmt.Println(string(jsonOutput))
+
+	if lbLabel == "" {
+		targets[0].LoadBalancer = true
+		return targets, nil
+	}
+
+	for i := range targets {
+		if targets[i].Label == lbLabel {
+			targets[i].LoadBalancer = true
+			return targets, nil
+		}
 	}
+
+	return nil, fmt.Errorf("load balancer target %s not found", lbLabel)
 }
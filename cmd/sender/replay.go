@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/capture"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// replaySkipHeaders are connection specific and left to the HTTP client
+var replaySkipHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// ReplayResult holds the results of a replay
+type ReplayResult struct {
+	File     string                      `json:"file"`
+	Records  int                         `json:"records"`
+	Speed    float64                     `json:"speed"`
+	MaxLagMs int64                       `json:"max_schedule_lag_ms"`
+	Stats    *sender.LoadBalancerSummary `json:"stats"`
+}
+
+// replayOffset returns when a request captured at t is due, relative to the
+// start of the replay of a capture that began at first
+func replayOffset(first, t time.Time, speed float64) time.Duration {
+	if speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(t.Sub(first)) / speed)
+}
+
+// replayEndpoint turns a captured request into the endpoint to send, bodies
+// are not captured so the request is sent without one
+func replayEndpoint(record capture.Record) sender.Endpoint {
+	endpoint := sender.Endpoint{
+		Method:  record.Method,
+		Path:    record.Path,
+		Headers: make(map[string]string, len(record.Headers)),
+	}
+	for name, value := range record.Headers {
+		if replaySkipHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		endpoint.Headers[name] = value
+	}
+	return endpoint
+}
+
+// runReplay reproduces the captured request stream against the load
+// balancer, keeping the original gaps between requests divided by
+// cfg.ReplaySpeed, or as fast as the workers allow when it is 0
+func runReplay(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	if cfg.Concurrency < 1 {
+		return fmt.Errorf("invalid concurrency: %d (must be at least 1)", cfg.Concurrency)
+	}
+	if cfg.ReplaySpeed < 0 {
+		return fmt.Errorf("invalid replay speed: %v (must not be negative)", cfg.ReplaySpeed)
+	}
+
+	records, err := capture.ReadFile(cfg.Replay)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("capture file %s contains no requests", cfg.Replay)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	first := records[0].Time
+	logger.Info("starting replay",
+		"url", cfg.LoadBalancerURL,
+		"file", cfg.Replay,
+		"requests", len(records),
+		"captured_span", records[len(records)-1].Time.Sub(first),
+		"speed", cfg.ReplaySpeed,
+		"concurrency", cfg.Concurrency)
+
+	start := time.Now()
+	schedule := make(chan int)
+	scheduled := make(chan struct{})
+	var maxLag time.Duration
+
+	// The scheduler hands every request to a worker when it is due, a request
+	// that has to wait for a free worker is sent late and counts as lag
+	go func() {
+		defer close(scheduled)
+		defer close(schedule)
+
+		for i, record := range records {
+			due := start.Add(replayOffset(first, record.Time, cfg.ReplaySpeed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+
+			select {
+			case schedule <- i:
+			case <-ctx.Done():
+				return
+			}
+
+			if cfg.ReplaySpeed > 0 {
+				maxLag = max(maxLag, time.Since(due))
+			}
+		}
+	}()
+
+	workerStats := make([]*sender.LoadBalancerStats, cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := range workerStats {
+		workerStats[i] = sender.NewLoadBalancerStats()
+
+		wg.Add(1)
+		go func(stats *sender.LoadBalancerStats) {
+			defer wg.Done()
+
+			for index := range schedule {
+				reqStart := time.Now()
+				hostname, proto, outcome, err := sender.SendWithRetry(ctx, cfg.Client, &cfg.Config, replayEndpoint(records[index]))
+				reqEnd := time.Now()
+
+				// Requests aborted by the interruption say nothing about the nodes
+				if ctx.Err() != nil {
+					return
+				}
+
+				entry := sender.TimelineEntry{Request: index + 1, Start: reqStart, End: reqEnd, Hostname: hostname, Protocol: proto}
+				if err != nil {
+					entry.Error = err.Error()
+				}
+				for _, o := range cfg.Observers {
+					o.Observe(entry)
+				}
+
+				stats.Record(hostname, proto, reqEnd.Sub(reqStart).Milliseconds(), err)
+				if cfg.Retry.Enabled() {
+					stats.RecordRetry(outcome, hostname, cfg.Retry.MaxAttempts, err)
+				}
+			}
+		}(workerStats[i])
+	}
+
+	wg.Wait()
+	// Workers stop early on interruption, maxLag is only final once the
+	// scheduler has returned too
+	<-scheduled
+
+	stats := sender.NewLoadBalancerStats()
+	for _, ws := range workerStats {
+		stats.Merge(ws)
+	}
+	stats.Interrupted = ctx.Err() != nil
+	stats.Finalize()
+
+	result := &ReplayResult{
+		File:     cfg.Replay,
+		Records:  len(records),
+		Speed:    cfg.ReplaySpeed,
+		MaxLagMs: maxLag.Milliseconds(),
+		Stats:    stats.Summary(),
+	}
+
+	logger.Info("replay completed",
+		"total_duration", time.Since(start),
+		"total_requests", stats.TotalRequests,
+		"failed_requests", stats.FailedRequests,
+		"max_schedule_lag", maxLag)
+
+	fmt.Println("\n=== Replay Results ===")
+	sender.WriteStats(os.Stdout, stats)
+	fmt.Printf("\nReplayed %d of %d requests at %.2fx speed, max schedule lag %d ms\n",
+		stats.TotalRequests, result.Records, result.Speed, result.MaxLagMs)
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal replay results to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/capture"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestReplayOffset(t *testing.T) {
+	first := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		after time.Duration
+		speed float64
+		want  time.Duration
+	}{
+		{name: "original timing", after: 3 * time.Second, speed: 1, want: 3 * time.Second},
+		{name: "twice as fast", after: 3 * time.Second, speed: 2, want: 1500 * time.Millisecond},
+		{name: "half speed", after: time.Second, speed: 0.5, want: 2 * time.Second},
+		{name: "as fast as possible", after: time.Minute, speed: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replayOffset(first, first.Add(tt.after), tt.speed); got != tt.want {
+				t.Errorf("replayOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayEndpoint(t *testing.T) {
+	endpoint := replayEndpoint(capture.Record{
+		Method: "POST",
+		Path:   "/api/orders?dry=true",
+		Headers: map[string]string{
+			"Content-Type":    "application/json",
+			"Accept-Encoding": "gzip",
+			"Connection":      "keep-alive",
+		},
+	})
+
+	if endpoint.Method != "POST" || endpoint.Path != "/api/orders?dry=true" {
+		t.Errorf("endpoint = %s %s, want POST /api/orders?dry=true", endpoint.Method, endpoint.Path)
+	}
+	if len(endpoint.Headers) != 1 || endpoint.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Headers = %v, want only Content-Type", endpoint.Headers)
+	}
+}
+
+// observerFunc adapts a function to the Observer interface
+type observerFunc func(entry sender.TimelineEntry)
+
+func (f observerFunc) Observe(entry sender.TimelineEntry) { f(entry) }
+
+func TestRunReplay_Interrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "pong", "hostname": "node-a"})
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "capture.ndjson")
+	writer, err := capture.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	for i := range 100 {
+		record := capture.Record{Time: first.Add(time.Duration(i) * time.Millisecond), Method: http.MethodGet, Path: "/api/ping"}
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Interrupt the replay while the scheduler is still handing out requests
+	var observed atomic.Int32
+	cfg := &SenderConfig{
+		Config: sender.Config{
+			LoadBalancerURL: server.URL,
+			Concurrency:     2,
+			Client:          server.Client(),
+			Observers: []sender.Observer{observerFunc(func(sender.TimelineEntry) {
+				if observed.Add(1) == 5 {
+					cancel()
+				}
+			})},
+		},
+		Replay:      filename,
+		ReplaySpeed: 1,
+	}
+
+	if err := runReplay(ctx, slog.New(slog.DiscardHandler), cfg); err != nil {
+		t.Fatalf("runReplay() error = %v", err)
+	}
+	if got := observed.Load(); got < 5 || got >= 100 {
+		t.Errorf("observed %d requests, want the replay to stop early", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes a staged load test loaded from a YAML file
+type Scenario struct {
+	Name   string          `yaml:"name"`
+	Stages []ScenarioStage `yaml:"stages"`
+}
+
+// ScenarioStage is a single stage of a scenario. Fields that are not set
+// fall back to the values given on the command line.
+type ScenarioStage struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Endpoints   []sender.Endpoint `yaml:"endpoints"`
+	Concurrency int               `yaml:"concurrency"`
+	Rate        int               `yaml:"rate"`
+	Requests    int               `yaml:"requests"`
+	Duration    time.Duration     `yaml:"duration"`
+	Assertions  StageAssertions   `yaml:"assertions"`
+}
+
+// StageAssertions are the conditions a stage must meet to pass,
+// assertions that are not set are not checked
+type StageAssertions struct {
+	MaxErrorRate *float64 `yaml:"max_error_rate"`
+	MaxAvgMs     *int64   `yaml:"max_avg_ms"`
+	MaxP99Ms     *int64   `yaml:"max_p99_ms"`
+	MinNodes     *int     `yaml:"min_nodes"`
+	MaxNodeShare *float64 `yaml:"max_node_share"`
+}
+
+// AssertionResult is the outcome of a single assertion
+type AssertionResult struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+// StageResult holds the statistics and assertion results of a stage
+type StageResult struct {
+	Name       string                      `json:"name"`
+	Summary    *sender.LoadBalancerSummary `json:"summary"`
+	P99RespMs  int64                       `json:"p99_response_time_ms"`
+	Assertions []AssertionResult           `json:"assertions"`
+	Passed     bool                        `json:"passed"`
+}
+
+// ScenarioResult holds the results of all stages and the overall statistics
+type ScenarioResult struct {
+	Name        string                      `json:"name"`
+	Stages      []StageResult               `json:"stages"`
+	Overall     *sender.LoadBalancerSummary `json:"overall"`
+	Passed      bool                        `json:"passed"`
+	Interrupted bool                        `json:"interrupted"`
+}
+
+// loadScenario reads and validates a scenario file
+func loadScenario(filename string) (*Scenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file %s: %w", filename, err)
+	}
+
+	// Reject unknown fields so that typos in assertions do not silently pass
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML scenario: %w", err)
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	return &scenario, nil
+}
+
+// Validate validates the scenario and fills in default stage names and methods
+func (s *Scenario) Validate() error {
+	if len(s.Stages) == 0 {
+		return fmt.Errorf("scenario must have at least one stage")
+	}
+
+	for i := range s.Stages {
+		stage := &s.Stages[i]
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage-%d", i+1)
+		}
+
+		if stage.Requests < 0 || stage.Concurrency < 0 || stage.Rate < 0 || stage.Duration < 0 {
+			return fmt.Errorf("stage %s: requests, concurrency, rate and duration cannot be negative", stage.Name)
+		}
+		if stage.Requests == 0 && stage.Duration == 0 {
+			return fmt.Errorf("stage %s: either requests or duration must be set", stage.Name)
+		}
+
+		for j := range stage.Endpoints {
+			endpoint := &stage.Endpoints[j]
+			if endpoint.Method == "" {
+				endpoint.Method = http.MethodGet
+			}
+			endpoint.Method = strings.ToUpper(endpoint.Method)
+
+			if !strings.HasPrefix(endpoint.Path, "/") {
+				return fmt.Errorf("stage %s: endpoint path %q must start with /", stage.Name, endpoint.Path)
+			}
+		}
+
+		if a := stage.Assertions.MaxErrorRate; a != nil && (*a < 0 || *a > 1) {
+			return fmt.Errorf("stage %s: max_error_rate must be between 0 and 1", stage.Name)
+		}
+		if a := stage.Assertions.MaxNodeShare; a != nil && (*a < 0 || *a > 1) {
+			return fmt.Errorf("stage %s: max_node_share must be between 0 and 1", stage.Name)
+		}
+	}
+
+	return nil
+}
+
+// senderConfig returns the configuration for running the stage
+func (s *ScenarioStage) senderConfig(base *SenderConfig) *SenderConfig {
+	cfg := *base
+	cfg.RequestCount = s.Requests
+	cfg.Rate = s.Rate
+	cfg.Duration = s.Duration
+
+	if len(s.Endpoints) > 0 {
+		cfg.Endpoints = s.Endpoints
+	}
+	if s.URL != "" {
+		cfg.LoadBalancerURL = s.URL
+	}
+	if s.Concurrency > 0 {
+		cfg.Concurrency = s.Concurrency
+	}
+
+	return &cfg
+}
+
+// check evaluates the assertions against the stage statistics
+func (a *StageAssertions) check(stats *sender.LoadBalancerStats) []AssertionResult {
+	results := make([]AssertionResult, 0)
+
+	if a.MaxErrorRate != nil {
+		var errorRate float64
+		if stats.TotalRequests > 0 {
+			errorRate = float64(stats.FailedRequests) / float64(stats.TotalRequests)
+		}
+		results = append(results, AssertionResult{
+			Name:     "max_error_rate",
+			Expected: fmt.Sprintf("<= %.4f", *a.MaxErrorRate),
+			Actual:   fmt.Sprintf("%.4f", errorRate),
+			Passed:   errorRate <= *a.MaxErrorRate,
+		})
+	}
+
+	if a.MaxAvgMs != nil {
+		results = append(results, AssertionResult{
+			Name:     "max_avg_ms",
+			Expected: fmt.Sprintf("<= %d", *a.MaxAvgMs),
+			Actual:   fmt.Sprintf("%d", stats.AverageRespTime),
+			Passed:   stats.AverageRespTime <= *a.MaxAvgMs,
+		})
+	}
+
+	if a.MaxP99Ms != nil {
+		p99 := stats.OverallLatency().Quantile(99)
+		results = append(results, AssertionResult{
+			Name:     "max_p99_ms",
+			Expected: fmt.Sprintf("<= %d", *a.MaxP99Ms),
+			Actual:   fmt.Sprintf("%d", p99),
+			Passed:   p99 <= *a.MaxP99Ms,
+		})
+	}
+
+	if a.MinNodes != nil {
+		results = append(results, AssertionResult{
+			Name:     "min_nodes",
+			Expected: fmt.Sprintf(">= %d", *a.MinNodes),
+			Actual:   fmt.Sprintf("%d", stats.AvailableNodes),
+			Passed:   stats.AvailableNodes >= *a.MinNodes,
+		})
+	}
+
+	if a.MaxNodeShare != nil {
+		var maxShare float64
+		for _, count := range stats.RequestsPerNode {
+			maxShare = max(maxShare, float64(count)/float64(stats.SuccessfulReqs))
+		}
+		results = append(results, AssertionResult{
+			Name:     "max_node_share",
+			Expected: fmt.Sprintf("<= %.4f", *a.MaxNodeShare),
+			Actual:   fmt.Sprintf("%.4f", maxShare),
+			Passed:   maxShare <= *a.MaxNodeShare,
+		})
+	}
+
+	return results
+}
+
+// runScenario runs the stages of the scenario file in order and prints
+// per-stage and overall results. It reports whether every assertion passed
+// and the scenario ran to completion.
+func runScenario(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) (bool, error) {
+	scenario, err := loadScenario(cfg.Scenario)
+	if err != nil {
+		return false, err
+	}
+
+	logger.Info("starting scenario", "name", scenario.Name, "stages", len(scenario.Stages))
+
+	result := &ScenarioResult{
+		Name:   scenario.Name,
+		Stages: make([]StageResult, 0, len(scenario.Stages)),
+		Passed: true,
+	}
+	overall := sender.NewLoadBalancerStats()
+
+	for i := range scenario.Stages {
+		stage := &scenario.Stages[i]
+		if ctx.Err() != nil {
+			break
+		}
+
+		logger.Info("starting stage", "stage", stage.Name)
+
+		run, err := sender.Run(ctx, &stage.senderConfig(cfg).Config)
+		if err != nil {
+			return false, fmt.Errorf("stage %s: %w", stage.Name, err)
+		}
+		stats := run.Stats
+		overall.Merge(stats)
+
+		stageResult := StageResult{
+			Name:       stage.Name,
+			Summary:    stats.Summary(),
+			P99RespMs:  stats.OverallLatency().Quantile(99),
+			Assertions: stage.Assertions.check(stats),
+			Passed:     !stats.Interrupted,
+		}
+		for _, assertion := range stageResult.Assertions {
+			stageResult.Passed = stageResult.Passed && assertion.Passed
+		}
+		result.Passed = result.Passed && stageResult.Passed
+		result.Stages = append(result.Stages, stageResult)
+
+		fmt.Printf("\n=== Stage %d/%d: %s ===\n", i+1, len(scenario.Stages), stage.Name)
+		sender.WriteStats(os.Stdout, stats)
+		displayAssertions(stageResult)
+	}
+
+	result.Interrupted = ctx.Err() != nil
+	result.Passed = result.Passed && !result.Interrupted
+
+	overall.Interrupted = result.Interrupted
+	overall.Finalize()
+	result.Overall = overall.Summary()
+
+	fmt.Println("\n=== Scenario Results ===")
+	sender.WriteStats(os.Stdout, overall)
+	for _, stage := range result.Stages {
+		fmt.Printf("%-20s: %s\n", stage.Name, passedLabel(stage.Passed))
+	}
+	fmt.Printf("Scenario: %s\n", passedLabel(result.Passed))
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal scenario results to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+
+	return result.Passed, nil
+}
+
+// displayAssertions prints the assertion results of a stage
+func displayAssertions(stage StageResult) {
+	if len(stage.Assertions) == 0 {
+		return
+	}
+
+	fmt.Println("\n=== Assertions ===")
+	for _, assertion := range stage.Assertions {
+		fmt.Printf("%-20s: %s (expected %s, actual %s)\n",
+			assertion.Name, passedLabel(assertion.Passed), assertion.Expected, assertion.Actual)
+	}
+}
+
+func passedLabel(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid scenario",
+			yaml: `
+name: test
+stages:
+  - requests: 10
+  - name: steady
+    duration: 5s
+    rate: 20
+    endpoints:
+      - method: post
+        path: /api/ping
+        body: "{}"
+    assertions:
+      max_error_rate: 0.01
+`,
+		},
+		{
+			name:    "no stages",
+			yaml:    "name: empty\n",
+			wantErr: true,
+			errMsg:  "invalid scenario: scenario must have at least one stage",
+		},
+		{
+			name: "stage without requests or duration",
+			yaml: `
+stages:
+  - name: endless
+`,
+			wantErr: true,
+			errMsg:  "invalid scenario: stage endless: either requests or duration",
+		},
+		{
+			name: "relative endpoint path",
+			yaml: `
+stages:
+  - requests: 1
+    endpoints:
+      - path: api/ping
+`,
+			wantErr: true,
+			errMsg:  "invalid scenario: stage stage-1: endpoint path",
+		},
+		{
+			name: "error rate out of range",
+			yaml: `
+stages:
+  - requests: 1
+    assertions:
+      max_error_rate: 5
+`,
+			wantErr: true,
+			errMsg:  "invalid scenario: stage stage-1: max_error_rate",
+		},
+		{
+			name: "unknown field",
+			yaml: `
+stages:
+  - requests: 1
+    assertions:
+      max_error: 0.1
+`,
+			wantErr: true,
+			errMsg:  "failed to parse YAML scenario",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "scenario.yaml")
+			if err := os.WriteFile(filename, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			scenario, err := loadScenario(filename)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loadScenario() error = nil, wantErr %v", tt.wantErr)
+				}
+				if !strings.HasPrefix(err.Error(), tt.errMsg) {
+					t.Errorf("loadScenario() error = %v, want error starting with %v", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadScenario() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if scenario.Stages[0].Name != "stage-1" {
+				t.Errorf("default stage name = %v, want stage-1", scenario.Stages[0].Name)
+			}
+			if scenario.Stages[1].Duration != 5*time.Second {
+				t.Errorf("Duration = %v, want 5s", scenario.Stages[1].Duration)
+			}
+			if scenario.Stages[1].Endpoints[0].Method != "POST" {
+				t.Errorf("Method = %v, want POST", scenario.Stages[1].Endpoints[0].Method)
+			}
+		})
+	}
+}
+
+func TestStageAssertions_Check(t *testing.T) {
+	stats := sender.NewLoadBalancerStats()
+	for i := 0; i < 8; i++ {
+		stats.Record("node-a", "HTTP/1.1", 10, nil)
+	}
+	stats.Record("node-b", "HTTP/1.1", 300, nil)
+	stats.Record("", "HTTP/1.1", 0, errTest)
+	stats.Finalize()
+
+	maxErrorRate := 0.05
+	maxP99 := int64(100)
+	minNodes := 2
+	maxShare := 0.9
+
+	assertions := StageAssertions{
+		MaxErrorRate: &maxErrorRate,
+		MaxP99Ms:     &maxP99,
+		MinNodes:     &minNodes,
+		MaxNodeShare: &maxShare,
+	}
+
+	want := map[string]bool{
+		"max_error_rate": false,
+		"max_p99_ms":     false,
+		"min_nodes":      true,
+		"max_node_share": true,
+	}
+
+	results := assertions.check(stats)
+	if len(results) != len(want) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(want))
+	}
+	for _, result := range results {
+		if result.Passed != want[result.Name] {
+			t.Errorf("%s passed = %v, want %v (actual %s)", result.Name, result.Passed, want[result.Name], result.Actual)
+		}
+	}
+}
+
+var errTest = errors.New("connection refused")
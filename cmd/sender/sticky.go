@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// ClientAffinity describes how well a single virtual client stuck to one node
+type ClientAffinity struct {
+	Client      int            `json:"client"`
+	Requests    int            `json:"requests"`
+	Failed      int            `json:"failed"`
+	PrimaryNode string         `json:"primary_node"`
+	Affinity    float64        `json:"affinity"`
+	Breaks      int            `json:"breaks"`
+	Nodes       map[string]int `json:"nodes"`
+}
+
+// AffinityReport holds the results of a sticky session verification run
+type AffinityReport struct {
+	Clients           int                         `json:"clients"`
+	RequestsPerClient int                         `json:"requests_per_client"`
+	StickyClients     int                         `json:"sticky_clients"`
+	MeanAffinity      float64                     `json:"mean_affinity"`
+	MinAffinity       float64                     `json:"min_affinity"`
+	TotalBreaks       int                         `json:"total_breaks"`
+	ClientsPerNode    map[string]int              `json:"clients_per_node"`
+	Stats             *sender.LoadBalancerSummary `json:"stats"`
+	PerClient         []ClientAffinity            `json:"per_client"`
+}
+
+// runSticky simulates cfg.StickyClients virtual clients, each keeping its
+// own cookie jar across cfg.StickyRequests sequential requests, and reports
+// how consistently every client was routed to the same node
+func runSticky(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	if cfg.Concurrency < 1 {
+		return fmt.Errorf("invalid concurrency: %d (must be at least 1)", cfg.Concurrency)
+	}
+	if cfg.StickyRequests < 1 {
+		return fmt.Errorf("invalid requests per client: %d (must be at least 1)", cfg.StickyRequests)
+	}
+
+	logger.Info("starting sticky session test",
+		"url", cfg.LoadBalancerURL,
+		"clients", cfg.StickyClients,
+		"requests_per_client", cfg.StickyRequests,
+		"concurrency", cfg.Concurrency)
+
+	clients := make(chan int)
+	go func() {
+		defer close(clients)
+		for id := 1; id <= cfg.StickyClients && ctx.Err() == nil; id++ {
+			select {
+			case clients <- id:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	affinities := make([]ClientAffinity, 0, cfg.StickyClients)
+	stats := sender.NewLoadBalancerStats()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for id := range clients {
+				affinity, clientStats, err := runStickyClient(ctx, cfg, id)
+				if err != nil {
+					logger.Error("failed to run virtual client", "client", id, "error", err)
+					continue
+				}
+
+				mu.Lock()
+				stats.Merge(clientStats)
+				if affinity.Requests > 0 {
+					affinities = append(affinities, affinity)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stats.Interrupted = ctx.Err() != nil
+	stats.Finalize()
+
+	report := buildAffinityReport(affinities, cfg.StickyRequests)
+	report.Stats = stats.Summary()
+
+	sender.WriteStats(os.Stdout, stats)
+	displayAffinity(logger, report)
+
+	return nil
+}
+
+// runStickyClient sends the requests of a single virtual client
+func runStickyClient(ctx context.Context, cfg *SenderConfig, id int) (ClientAffinity, *sender.LoadBalancerStats, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return ClientAffinity{}, nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+
+	// Share the transport so that only the cookies differ between clients
+	client := &http.Client{
+		Transport: cfg.Client.Transport,
+		Timeout:   cfg.Client.Timeout,
+		Jar:       jar,
+	}
+
+	affinity := ClientAffinity{
+		Client: id,
+		Nodes:  make(map[string]int),
+	}
+	stats := sender.NewLoadBalancerStats()
+	var lastNode string
+
+	for reqNum := 1; reqNum <= cfg.StickyRequests; reqNum++ {
+		reqStart := time.Now()
+		hostname, proto, outcome, err := sender.SendWithRetry(ctx, client, &cfg.Config, cfg.Endpoint(reqNum))
+		reqEnd := time.Now()
+
+		// Requests aborted by the interruption say nothing about the nodes
+		if ctx.Err() != nil {
+			break
+		}
+
+		entry := sender.TimelineEntry{Request: reqNum, Start: reqStart, End: reqEnd, Hostname: hostname, Protocol: proto}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		for _, o := range cfg.Observers {
+			o.Observe(entry)
+		}
+
+		stats.Record(hostname, proto, reqEnd.Sub(reqStart).Milliseconds(), err)
+		if cfg.Retry.Enabled() {
+			stats.RecordRetry(outcome, hostname, cfg.Retry.MaxAttempts, err)
+		}
+		affinity.Requests++
+
+		if err != nil {
+			affinity.Failed++
+			continue
+		}
+
+		affinity.Nodes[hostname]++
+		if lastNode != "" && hostname != lastNode {
+			affinity.Breaks++
+		}
+		lastNode = hostname
+	}
+
+	successful := affinity.Requests - affinity.Failed
+	for hostname, count := range affinity.Nodes {
+		// Ties go to the alphabetically first node to keep reports stable
+		primary := affinity.Nodes[affinity.PrimaryNode]
+		if count > primary || (count == primary && hostname < affinity.PrimaryNode) {
+			affinity.PrimaryNode = hostname
+		}
+	}
+	if successful > 0 {
+		affinity.Affinity = float64(affinity.Nodes[affinity.PrimaryNode]) / float64(successful)
+	}
+
+	return affinity, stats, nil
+}
+
+// buildAffinityReport aggregates the per-client results, clients without
+// any successful request are left out of the affinity figures
+func buildAffinityReport(affinities []ClientAffinity, requestsPerClient int) *AffinityReport {
+	sort.Slice(affinities, func(i, j int) bool {
+		return affinities[i].Client < affinities[j].Client
+	})
+
+	report := &AffinityReport{
+		Clients:           len(affinities),
+		RequestsPerClient: requestsPerClient,
+		ClientsPerNode:    make(map[string]int),
+		PerClient:         affinities,
+	}
+
+	var measured int
+	var sum float64
+	for _, a := range affinities {
+		report.TotalBreaks += a.Breaks
+		if a.PrimaryNode == "" {
+			continue
+		}
+
+		if measured == 0 || a.Affinity < report.MinAffinity {
+			report.MinAffinity = a.Affinity
+		}
+		measured++
+		sum += a.Affinity
+
+		report.ClientsPerNode[a.PrimaryNode]++
+		if a.Breaks == 0 && a.Affinity == 1 {
+			report.StickyClients++
+		}
+	}
+
+	if measured > 0 {
+		report.MeanAffinity = sum / float64(measured)
+	}
+
+	return report
+}
+
+// displayAffinity prints the sticky session results
+func displayAffinity(logger *slog.Logger, report *AffinityReport) {
+	fmt.Println("\n=== Session Affinity ===")
+	fmt.Printf("Clients: %d, Requests Per Client: %d\n", report.Clients, report.RequestsPerClient)
+	fmt.Printf("Sticky Clients: %d (%.1f%%)\n",
+		report.StickyClients, float64(report.StickyClients)/float64(max(report.Clients, 1))*100)
+	fmt.Printf("Mean Affinity: %.1f%%, Min Affinity: %.1f%%\n", report.MeanAffinity*100, report.MinAffinity*100)
+	fmt.Printf("Affinity Breaks: %d\n", report.TotalBreaks)
+
+	fmt.Println("\n=== Clients Per Node ===")
+	nodes := make([]string, 0, len(report.ClientsPerNode))
+	for hostname := range report.ClientsPerNode {
+		nodes = append(nodes, hostname)
+	}
+	sort.Strings(nodes)
+	for _, hostname := range nodes {
+		count := report.ClientsPerNode[hostname]
+		fmt.Printf("%-20s: %4d clients (%.1f%%)\n", hostname, count, float64(count)/float64(report.Clients)*100)
+	}
+
+	var broken int
+	for _, a := range report.PerClient {
+		if a.Breaks == 0 {
+			continue
+		}
+		if broken == 0 {
+			fmt.Println("\n=== Clients With Affinity Breaks ===")
+		}
+		broken++
+		fmt.Printf("client %-5d: primary=%s, affinity=%.1f%%, breaks=%d, nodes=%d\n",
+			a.Client, a.PrimaryNode, a.Affinity*100, a.Breaks, len(a.Nodes))
+	}
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal affinity report to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+}
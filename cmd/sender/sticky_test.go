@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildAffinityReport(t *testing.T) {
+	affinities := []ClientAffinity{
+		{Client: 2, Requests: 4, PrimaryNode: "node-b", Affinity: 0.75, Breaks: 2},
+		{Client: 1, Requests: 4, PrimaryNode: "node-a", Affinity: 1},
+		{Client: 3, Requests: 4, PrimaryNode: "node-a", Affinity: 1},
+		{Client: 4, Requests: 2, Failed: 2},
+	}
+
+	report := buildAffinityReport(affinities, 4)
+
+	if report.Clients != 4 {
+		t.Errorf("Clients = %d, want 4", report.Clients)
+	}
+	if report.StickyClients != 2 {
+		t.Errorf("StickyClients = %d, want 2", report.StickyClients)
+	}
+	if report.TotalBreaks != 2 {
+		t.Errorf("TotalBreaks = %d, want 2", report.TotalBreaks)
+	}
+	if report.MinAffinity != 0.75 {
+		t.Errorf("MinAffinity = %v, want 0.75", report.MinAffinity)
+	}
+	if want := 2.75 / 3; report.MeanAffinity != want {
+		t.Errorf("MeanAffinity = %v, want %v", report.MeanAffinity, want)
+	}
+	if report.ClientsPerNode["node-a"] != 2 || report.ClientsPerNode["node-b"] != 1 {
+		t.Errorf("ClientsPerNode = %v, want node-a: 2, node-b: 1", report.ClientsPerNode)
+	}
+	if report.PerClient[0].Client != 1 {
+		t.Errorf("PerClient[0].Client = %d, want clients sorted by id", report.PerClient[0].Client)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// Target modes
+const (
+	TargetsSequential = "sequential"
+	TargetsConcurrent = "concurrent"
+)
+
+// Target is a URL requests are sent to, either the load balancer
+// or one of the application nodes directly
+type Target struct {
+	Label        string `json:"label"`
+	URL          string `json:"url"`
+	LoadBalancer bool   `json:"load_balancer"`
+}
+
+// TargetResult holds the statistics collected for a single target, the
+// response times are measured in microseconds so that differences below a
+// millisecond between the targets are not rounded away
+type TargetResult struct {
+	Target
+	Summary   *sender.LoadBalancerSummary `json:"summary"`
+	AvgRespMs float64                     `json:"average_response_time_ms"`
+	P50RespMs float64                     `json:"p50_response_time_ms"`
+	P99RespMs float64                     `json:"p99_response_time_ms"`
+}
+
+// LoadBalancerOverhead is the latency added by the load balancer compared
+// to the mean of the direct targets
+type LoadBalancerOverhead struct {
+	AverageRespTime float64 `json:"average_response_time_ms"`
+	P50RespTime     float64 `json:"p50_response_time_ms"`
+	P99RespTime     float64 `json:"p99_response_time_ms"`
+}
+
+// latencyRecorder records the response times of successful requests in
+// microseconds
+type latencyRecorder struct {
+	mu        sync.Mutex
+	histogram *sender.LatencyHistogram
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{histogram: sender.NewLatencyHistogram()}
+}
+
+// Observe records the response time of a successful request
+func (l *latencyRecorder) Observe(entry sender.TimelineEntry) {
+	if entry.Error != "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.histogram.Record(entry.End.Sub(entry.Start).Microseconds())
+}
+
+// milliseconds returns the mean, p50 and p99 in fractional milliseconds
+func (l *latencyRecorder) milliseconds() (avg, p50, p99 float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.histogram
+	if h.Count > 0 {
+		avg = float64(h.Sum) / float64(h.Count) / 1000
+	}
+	return avg, float64(h.Quantile(50)) / 1000, float64(h.Quantile(99)) / 1000
+}
+
+// TargetComparison holds the results of all targets
+type TargetComparison struct {
+	Mode     string                `json:"mode"`
+	Targets  []TargetResult        `json:"targets"`
+	Overhead *LoadBalancerOverhead `json:"overhead,omitempty"`
+}
+
+// parseTarget parses a target given as [label=]url, the label defaults to
+// the host of the URL
+func parseTarget(value string) (Target, error) {
+	var target Target
+
+	// A label can only come before the scheme, an "=" after it belongs to the URL
+	if eq := strings.Index(value, "="); eq > 0 && eq < strings.Index(value, "://") {
+		target.Label = value[:eq]
+		value = value[eq+1:]
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return Target{}, fmt.Errorf("invalid target URL %q", value)
+	}
+
+	target.URL = strings.TrimSuffix(value, "/")
+	if target.Label == "" {
+		target.Label = parsed.Host
+	}
+
+	return target, nil
+}
+
+// loadTargetsFile reads one [label=]url target per line,
+// empty lines and lines starting with # are ignored
+func loadTargetsFile(filename string) ([]Target, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open targets file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	var targets []Target
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+
+		target, err := parseTarget(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, line, err)
+		}
+		targets = append(targets, target)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets file %s: %w", filename, err)
+	}
+
+	return targets, nil
+}
+
+// runTargets sends the configured requests to every target, one after
+// another or all at once, and prints the statistics side by side together
+// with the overhead of the load balancer over the direct targets
+func runTargets(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	results := make([]TargetResult, len(cfg.Targets))
+	errs := make([]error, len(cfg.Targets))
+
+	run := func(i int) {
+		target := cfg.Targets[i]
+		targetCfg := cfg.Config
+		targetCfg.LoadBalancerURL = target.URL
+		targetCfg.Logger = logger.With("target", target.Label)
+		latencies := newLatencyRecorder()
+		targetCfg.Observers = append(slices.Clone(cfg.Observers), latencies)
+
+		run, err := sender.Run(ctx, &targetCfg)
+		if err != nil {
+			errs[i] = fmt.Errorf("target %s: %w", target.Label, err)
+			return
+		}
+
+		result := TargetResult{Target: target, Summary: run.Stats.Summary()}
+		result.AvgRespMs, result.P50RespMs, result.P99RespMs = latencies.milliseconds()
+		results[i] = result
+	}
+
+	if cfg.TargetsMode == TargetsConcurrent {
+		var wg sync.WaitGroup
+		for i := range cfg.Targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run(i)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range cfg.Targets {
+			// Targets that were not started before an interruption are left out
+			if ctx.Err() != nil {
+				break
+			}
+			run(i)
+		}
+	}
+
+	comparison := &TargetComparison{
+		Mode:    cfg.TargetsMode,
+		Targets: make([]TargetResult, 0, len(results)),
+	}
+	for i, result := range results {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if result.Summary != nil {
+			comparison.Targets = append(comparison.Targets, result)
+		}
+	}
+	comparison.Overhead = loadBalancerOverhead(comparison.Targets)
+	displayComparison(logger, comparison)
+
+	return nil
+}
+
+// loadBalancerOverhead compares the load balancer target with the mean of
+// the direct targets, it returns nil when either of them is missing
+func loadBalancerOverhead(results []TargetResult) *LoadBalancerOverhead {
+	var lb *TargetResult
+	var direct []TargetResult
+
+	for i := range results {
+		if results[i].LoadBalancer {
+			lb = &results[i]
+		} else if results[i].Summary.SuccessfulReqs > 0 {
+			direct = append(direct, results[i])
+		}
+	}
+
+	if lb == nil || lb.Summary.SuccessfulReqs == 0 || len(direct) == 0 {
+		return nil
+	}
+
+	var avg, p50, p99 float64
+	for _, r := range direct {
+		avg += r.AvgRespMs
+		p50 += r.P50RespMs
+		p99 += r.P99RespMs
+	}
+	n := float64(len(direct))
+
+	return &LoadBalancerOverhead{
+		AverageRespTime: lb.AvgRespMs - avg/n,
+		P50RespTime:     lb.P50RespMs - p50/n,
+		P99RespTime:     lb.P99RespMs - p99/n,
+	}
+}
+
+// displayComparison prints the target statistics side by side
+func displayComparison(logger *slog.Logger, comparison *TargetComparison) {
+	fmt.Printf("\n=== Target Comparison (%s) ===\n", comparison.Mode)
+	fmt.Printf("%-20s %-4s %8s %8s %6s %9s %9s %9s\n",
+		"Target", "Type", "Requests", "Failed", "Nodes", "Avg", "p50", "p99")
+
+	for _, r := range comparison.Targets {
+		kind := "node"
+		if r.LoadBalancer {
+			kind = "lb"
+		}
+		fmt.Printf("%-20s %-4s %8d %8d %6d %7.2fms %7.2fms %7.2fms\n",
+			r.Label, kind,
+			r.Summary.TotalRequests, r.Summary.FailedRequests, r.Summary.AvailableNodes,
+			r.AvgRespMs, r.P50RespMs, r.P99RespMs)
+	}
+
+	if comparison.Overhead != nil {
+		fmt.Println("\n=== Load Balancer Overhead (vs mean of direct targets) ===")
+		fmt.Printf("avg=%+.2fms, p50=%+.2fms, p99=%+.2fms\n",
+			comparison.Overhead.AverageRespTime,
+			comparison.Overhead.P50RespTime,
+			comparison.Overhead.P99RespTime)
+	}
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(comparison, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal target comparison to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+}
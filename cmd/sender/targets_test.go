@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Target
+		wantErr bool
+	}{
+		{
+			value: "http://127.0.0.1:9000/",
+			want:  Target{Label: "127.0.0.1:9000", URL: "http://127.0.0.1:9000"},
+		},
+		{
+			value: "lb=https://alcatraz.rest",
+			want:  Target{Label: "lb", URL: "https://alcatraz.rest"},
+		},
+		{
+			value: "https://alcatraz.rest/?a=b",
+			want:  Target{Label: "alcatraz.rest", URL: "https://alcatraz.rest/?a=b"},
+		},
+		{
+			value:   "node1=127.0.0.1:9000",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTarget(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTarget() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTarget() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargets_LoadBalancer(t *testing.T) {
+	urls := []string{"node1=http://10.0.0.1:9080", "lb=http://10.0.0.10"}
+
+	targets, err := parseTargets(urls, "", "lb")
+	if err != nil {
+		t.Fatalf("parseTargets() error = %v", err)
+	}
+	if targets[0].LoadBalancer || !targets[1].LoadBalancer {
+		t.Errorf("parseTargets() = %+v, want lb marked as load balancer", targets)
+	}
+
+	if _, err := parseTargets(urls, "", "missing"); err == nil {
+		t.Error("parseTargets() with unknown lb label error = nil, want error")
+	}
+	if _, err := parseTargets([]string{"a=http://x", "a=http://y"}, "", ""); err == nil {
+		t.Error("parseTargets() with duplicate labels error = nil, want error")
+	}
+}
+
+func TestLoadBalancerOverhead(t *testing.T) {
+	result := func(lb bool, avg, p50, p99 float64) TargetResult {
+		return TargetResult{
+			Target:    Target{LoadBalancer: lb},
+			Summary:   &sender.LoadBalancerSummary{SuccessfulReqs: 10},
+			AvgRespMs: avg,
+			P50RespMs: p50,
+			P99RespMs: p99,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		results []TargetResult
+		want    *LoadBalancerOverhead
+	}{
+		{
+			name:    "whole milliseconds",
+			results: []TargetResult{result(true, 12, 10, 40), result(false, 4, 3, 20), result(false, 6, 5, 30)},
+			want:    &LoadBalancerOverhead{AverageRespTime: 7, P50RespTime: 6, P99RespTime: 15},
+		},
+		{
+			name:    "below a millisecond",
+			results: []TargetResult{result(true, 0.75, 0.5, 1.5), result(false, 0.25, 0.25, 0.5), result(false, 0.5, 0.25, 1)},
+			want:    &LoadBalancerOverhead{AverageRespTime: 0.375, P50RespTime: 0.25, P99RespTime: 0.75},
+		},
+		{
+			name:    "mean of odd sums is not truncated",
+			results: []TargetResult{result(true, 5, 5, 5), result(false, 1, 1, 1), result(false, 2, 2, 2)},
+			want:    &LoadBalancerOverhead{AverageRespTime: 3.5, P50RespTime: 3.5, P99RespTime: 3.5},
+		},
+		{
+			name:    "no direct targets",
+			results: []TargetResult{result(true, 1, 1, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loadBalancerOverhead(tt.results)
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("loadBalancerOverhead() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyRecorder(t *testing.T) {
+	start := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	recorder := newLatencyRecorder()
+	for _, us := range []int{250, 500, 750, 1500} {
+		recorder.Observe(sender.TimelineEntry{Start: start, End: start.Add(time.Duration(us) * time.Microsecond)})
+	}
+	recorder.Observe(sender.TimelineEntry{Start: start, End: start.Add(time.Second), Error: "timeout"})
+
+	avg, p50, p99 := recorder.milliseconds()
+	if avg != 0.75 || p50 != 0.5 || p99 != 1.5 {
+		t.Errorf("milliseconds() = %v, %v, %v, want 0.75, 0.5, 1.5", avg, p50, p99)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// watchTimelineLimit caps the timeline in watch mode, which runs until it is
+// interrupted, at the most recent requests
+const watchTimelineLimit = 100_000
+
+// Timeline collects per-request entries, it is safe for concurrent use
+type Timeline struct {
+	mu sync.Mutex
+	// limit is the number of entries kept, once reached the oldest entry
+	// is overwritten. 0 keeps every entry.
+	limit   int
+	entries []sender.TimelineEntry
+	oldest  int
+	dropped int
+}
+
+// NewTimeline creates a timeline that keeps the last limit entries,
+// or every entry when limit is 0
+func NewTimeline(limit int) *Timeline {
+	return &Timeline{limit: limit}
+}
+
+// AvailabilityReport describes when each node served traffic during a run
+type AvailabilityReport struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	GapThresholdMs int64     `json:"gap_threshold_ms"`
+	TotalRequests  int       `json:"total_requests"`
+	FailedRequests int       `json:"failed_requests"`
+	// DroppedRequests are older requests that no longer fit in the timeline
+	DroppedRequests int                    `json:"dropped_requests,omitempty"`
+	Nodes           []NodeAvailability     `json:"nodes"`
+	Timeline        []sender.TimelineEntry `json:"timeline"`
+}
+
+// NodeAvailability holds the availability of a single node
+type NodeAvailability struct {
+	Hostname  string    `json:"hostname"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  int       `json:"requests"`
+	Gaps      []NodeGap `json:"gaps"`
+}
+
+// NodeGap is a period in which a node did not serve any request.
+// A gap without an end means the node never came back.
+type NodeGap struct {
+	Start             time.Time   `json:"start"`
+	End               *time.Time  `json:"end,omitempty"`
+	DurationMs        int64       `json:"duration_ms"`
+	ErrorBurst        *ErrorBurst `json:"error_burst,omitempty"`
+	RebalancedAt      *time.Time  `json:"rebalanced_at,omitempty"`
+	RebalancedAfterMs int64       `json:"rebalanced_after_ms,omitempty"`
+}
+
+// ErrorBurst is a cluster of failed requests around a node disappearance
+type ErrorBurst struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Errors int       `json:"errors"`
+}
+
+// Observe appends an entry to the timeline
+func (t *Timeline) Observe(entry sender.TimelineEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.limit > 0 && len(t.entries) == t.limit {
+		t.entries[t.oldest] = entry
+		t.oldest = (t.oldest + 1) % t.limit
+		t.dropped++
+		return
+	}
+	t.entries = append(t.entries, entry)
+}
+
+// Dropped returns the number of entries overwritten because of the limit
+func (t *Timeline) Dropped() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.dropped
+}
+
+// Entries returns a copy of the recorded entries ordered by completion time
+func (t *Timeline) Entries() []sender.TimelineEntry {
+	t.mu.Lock()
+	entries := make([]sender.TimelineEntry, 0, len(t.entries))
+	entries = append(entries, t.entries[t.oldest:]...)
+	entries = append(entries, t.entries[:t.oldest]...)
+	t.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].End.Before(entries[j].End)
+	})
+
+	return entries
+}
+
+// buildAvailabilityReport analyses the timeline. A node that did not serve
+// any request for longer than gapThreshold is considered gone for that period.
+// The error burst of a gap is the first cluster of failures (failures closer
+// than gapThreshold to each other) starting around the node's last response,
+// and traffic is considered rebalanced at the first successful request after
+// that burst.
+func buildAvailabilityReport(entries []sender.TimelineEntry, gapThreshold time.Duration) *AvailabilityReport {
+	report := &AvailabilityReport{
+		GapThresholdMs: gapThreshold.Milliseconds(),
+		TotalRequests:  len(entries),
+		Nodes:          make([]NodeAvailability, 0),
+		Timeline:       entries,
+	}
+	if len(entries) == 0 {
+		return report
+	}
+
+	report.Start = entries[0].Start
+	report.End = entries[len(entries)-1].End
+	for _, e := range entries {
+		if e.Start.Before(report.Start) {
+			report.Start = e.Start
+		}
+	}
+
+	seen := make(map[string][]time.Time)
+	for _, e := range entries {
+		if e.Error != "" {
+			report.FailedRequests++
+			continue
+		}
+		seen[e.Hostname] = append(seen[e.Hostname], e.End)
+	}
+
+	hostnames := make([]string, 0, len(seen))
+	for hostname := range seen {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	for _, hostname := range hostnames {
+		times := seen[hostname]
+		node := NodeAvailability{
+			Hostname:  hostname,
+			FirstSeen: times[0],
+			LastSeen:  times[len(times)-1],
+			Requests:  len(times),
+			Gaps:      make([]NodeGap, 0),
+		}
+
+		for i := 1; i < len(times); i++ {
+			if times[i].Sub(times[i-1]) > gapThreshold {
+				end := times[i]
+				node.Gaps = append(node.Gaps, analyseGap(entries, times[i-1], &end, gapThreshold))
+			}
+		}
+
+		if report.End.Sub(node.LastSeen) > gapThreshold {
+			node.Gaps = append(node.Gaps, analyseGap(entries, node.LastSeen, nil, gapThreshold))
+		}
+
+		report.Nodes = append(report.Nodes, node)
+	}
+
+	return report
+}
+
+// analyseGap finds the error burst and rebalancing time for a single gap
+func analyseGap(entries []sender.TimelineEntry, start time.Time, end *time.Time, gapThreshold time.Duration) NodeGap {
+	gap := NodeGap{Start: start, End: end}
+	if end != nil {
+		gap.DurationMs = end.Sub(start).Milliseconds()
+	} else {
+		gap.DurationMs = entries[len(entries)-1].End.Sub(start).Milliseconds()
+	}
+
+	// Requests that were already in flight when the node went away may fail
+	// slightly before its last successful response completed
+	windowStart := start.Add(-gapThreshold)
+	settled := start
+
+	for _, e := range entries {
+		if e.End.Before(windowStart) || e.Error == "" {
+			continue
+		}
+
+		if gap.ErrorBurst == nil {
+			if e.End.Sub(start) > gapThreshold {
+				break
+			}
+			gap.ErrorBurst = &ErrorBurst{Start: e.End, End: e.End}
+		} else if e.End.Sub(gap.ErrorBurst.End) > gapThreshold {
+			break
+		}
+
+		gap.ErrorBurst.End = e.End
+		gap.ErrorBurst.Errors++
+	}
+
+	if gap.ErrorBurst != nil && gap.ErrorBurst.End.After(settled) {
+		settled = gap.ErrorBurst.End
+	}
+
+	for _, e := range entries {
+		if e.Error == "" && e.End.After(settled) {
+			rebalancedAt := e.End
+			gap.RebalancedAt = &rebalancedAt
+			gap.RebalancedAfterMs = rebalancedAt.Sub(start).Milliseconds()
+			break
+		}
+	}
+
+	return gap
+}
+
+// writeAvailabilityReport writes the report as indented JSON to the given file
+func writeAvailabilityReport(report *AvailabilityReport, filename string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal availability report: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write availability report %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// displayAvailability prints a human readable version of the report
+func displayAvailability(report *AvailabilityReport) {
+	fmt.Println("\n=== Node Availability ===")
+	if report.DroppedRequests > 0 {
+		fmt.Printf("Only the last %d requests are covered, %d older ones were dropped\n",
+			report.TotalRequests, report.DroppedRequests)
+	}
+	for _, node := range report.Nodes {
+		fmt.Printf("%-20s: first=%s, last=%s, requests=%d, gaps=%d\n",
+			node.Hostname,
+			node.FirstSeen.Format(time.RFC3339Nano),
+			node.LastSeen.Format(time.RFC3339Nano),
+			node.Requests,
+			len(node.Gaps))
+
+		for _, gap := range node.Gaps {
+			state := "never returned"
+			if gap.End != nil {
+				state = "returned at " + gap.End.Format(time.RFC3339Nano)
+			}
+			fmt.Printf("  gap from %s, %d ms, %s\n", gap.Start.Format(time.RFC3339Nano), gap.DurationMs, state)
+
+			if gap.ErrorBurst != nil {
+				fmt.Printf("  error burst: %d errors over %d ms\n",
+					gap.ErrorBurst.Errors, gap.ErrorBurst.End.Sub(gap.ErrorBurst.Start).Milliseconds())
+			}
+			if gap.RebalancedAt != nil {
+				fmt.Printf("  rebalanced after %d ms\n", gap.RebalancedAfterMs)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+func TestBuildAvailabilityReport(t *testing.T) {
+	base := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	entry := func(ms int, hostname string, failed bool) sender.TimelineEntry {
+		e := sender.TimelineEntry{Start: at(ms - 5), End: at(ms), Hostname: hostname}
+		if failed {
+			e.Hostname = ""
+			e.Error = "connection refused"
+		}
+		return e
+	}
+
+	// node-b disappears after 300ms, three requests fail
+	// and node-a takes over all traffic at 700ms
+	entries := []sender.TimelineEntry{
+		entry(100, "node-a", false),
+		entry(200, "node-b", false),
+		entry(300, "node-b", false),
+		entry(400, "", true),
+		entry(500, "", true),
+		entry(600, "", true),
+		entry(700, "node-a", false),
+		entry(2500, "node-a", false),
+		entry(2600, "node-a", false),
+	}
+
+	report := buildAvailabilityReport(entries, time.Second)
+
+	if report.TotalRequests != 9 {
+		t.Errorf("TotalRequests = %d, want 9", report.TotalRequests)
+	}
+	if report.FailedRequests != 3 {
+		t.Errorf("FailedRequests = %d, want 3", report.FailedRequests)
+	}
+	if len(report.Nodes) != 2 {
+		t.Fatalf("len(Nodes) = %d, want 2", len(report.Nodes))
+	}
+
+	nodeA := report.Nodes[0]
+	if nodeA.Hostname != "node-a" || nodeA.Requests != 4 {
+		t.Errorf("node-a = %s with %d requests, want node-a with 4", nodeA.Hostname, nodeA.Requests)
+	}
+	if len(nodeA.Gaps) != 1 || nodeA.Gaps[0].End == nil || !nodeA.Gaps[0].End.Equal(at(2500)) {
+		t.Errorf("node-a gaps = %+v, want a single gap ending at 2500ms", nodeA.Gaps)
+	}
+
+	nodeB := report.Nodes[1]
+	if !nodeB.FirstSeen.Equal(at(200)) || !nodeB.LastSeen.Equal(at(300)) {
+		t.Errorf("node-b seen %v - %v, want 200ms - 300ms", nodeB.FirstSeen, nodeB.LastSeen)
+	}
+	if len(nodeB.Gaps) != 1 {
+		t.Fatalf("len(node-b gaps) = %d, want 1", len(nodeB.Gaps))
+	}
+
+	gap := nodeB.Gaps[0]
+	if gap.End != nil {
+		t.Errorf("gap end = %v, want nil for a node that never returned", gap.End)
+	}
+	if gap.DurationMs != 2300 {
+		t.Errorf("gap DurationMs = %d, want 2300", gap.DurationMs)
+	}
+	if gap.ErrorBurst == nil {
+		t.Fatal("gap ErrorBurst = nil, want burst")
+	}
+	if gap.ErrorBurst.Errors != 3 || !gap.ErrorBurst.Start.Equal(at(400)) || !gap.ErrorBurst.End.Equal(at(600)) {
+		t.Errorf("ErrorBurst = %+v, want 3 errors from 400ms to 600ms", gap.ErrorBurst)
+	}
+	if gap.RebalancedAfterMs != 400 {
+		t.Errorf("RebalancedAfterMs = %d, want 400", gap.RebalancedAfterMs)
+	}
+}
+
+func TestBuildAvailabilityReport_Empty(t *testing.T) {
+	report := buildAvailabilityReport(nil, time.Second)
+
+	if report.TotalRequests != 0 || len(report.Nodes) != 0 {
+		t.Errorf("report = %+v, want empty report", report)
+	}
+}
+
+func TestTimeline_Limit(t *testing.T) {
+	base := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	observe := func(timeline *Timeline, n int) {
+		for i := 1; i <= n; i++ {
+			end := base.Add(time.Duration(i) * time.Millisecond)
+			timeline.Observe(sender.TimelineEntry{Request: i, Start: end, End: end})
+		}
+	}
+
+	tests := []struct {
+		name        string
+		limit       int
+		observed    int
+		wantFirst   int
+		wantLen     int
+		wantDropped int
+	}{
+		{name: "unlimited", limit: 0, observed: 10, wantFirst: 1, wantLen: 10},
+		{name: "below the limit", limit: 10, observed: 7, wantFirst: 1, wantLen: 7},
+		{name: "at the limit", limit: 10, observed: 10, wantFirst: 1, wantLen: 10},
+		{name: "over the limit", limit: 4, observed: 10, wantFirst: 7, wantLen: 4, wantDropped: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline := NewTimeline(tt.limit)
+			observe(timeline, tt.observed)
+
+			entries := timeline.Entries()
+			if len(entries) != tt.wantLen {
+				t.Fatalf("len(Entries()) = %d, want %d", len(entries), tt.wantLen)
+			}
+			for i, entry := range entries {
+				if entry.Request != tt.wantFirst+i {
+					t.Errorf("Entries()[%d].Request = %d, want %d", i, entry.Request, tt.wantFirst+i)
+				}
+			}
+			if got := timeline.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// watchSample holds the outcome of a single ping sent in watch mode, the
+// hostname of a failed ping is the node that answered it or
+// sender.UnknownNode
+type watchSample struct {
+	at       time.Time
+	hostname string
+	latency  int64
+	failed   bool
+}
+
+// watcher continuously pings the load balancer, keeps a rolling window
+// of results and tracks which nodes are currently serving traffic
+type watcher struct {
+	logger    *slog.Logger
+	out       io.Writer
+	client    *http.Client
+	cfg       *SenderConfig
+	observers []sender.Observer
+	requests  atomic.Int64
+
+	mu       sync.Mutex
+	samples  []watchSample
+	lastSeen map[string]time.Time
+	gone     map[string]bool
+	// failing holds the nodes whose error rate is above the threshold and
+	// since when
+	failing map[string]time.Time
+}
+
+// watch runs the sender in continuous monitoring mode until ctx is cancelled,
+// every ping is passed to the observers
+func watch(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	if cfg.WatchRate < 1 {
+		return fmt.Errorf("invalid rate: %d (must be at least 1)", cfg.WatchRate)
+	}
+	if cfg.WatchInterval <= 0 || cfg.WatchWindow <= 0 || cfg.NodeTimeout <= 0 {
+		return fmt.Errorf("interval, window and node timeout must be positive")
+	}
+
+	w := &watcher{
+		logger:    logger,
+		out:       os.Stdout,
+		client:    cfg.Client,
+		cfg:       cfg,
+		observers: cfg.Observers,
+		lastSeen:  make(map[string]time.Time),
+		gone:      make(map[string]bool),
+		failing:   make(map[string]time.Time),
+	}
+
+	return w.run(ctx)
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	w.logger.Info("starting watch",
+		"url", w.cfg.LoadBalancerURL,
+		"rate", w.cfg.WatchRate,
+		"interval", w.cfg.WatchInterval,
+		"window", w.cfg.WatchWindow,
+		"node_timeout", w.cfg.NodeTimeout)
+
+	pingTicker := time.NewTicker(time.Second / time.Duration(w.cfg.WatchRate))
+	defer pingTicker.Stop()
+
+	summaryTicker := time.NewTicker(w.cfg.WatchInterval)
+	defer summaryTicker.Stop()
+
+	// Check node state several times per timeout so that disappearances
+	// are reported close to the moment they happen
+	checkTicker := time.NewTicker(max(w.cfg.NodeTimeout/4, 10*time.Millisecond))
+	defer checkTicker.Stop()
+
+	semaphore := make(chan struct{}, max(w.cfg.Concurrency, 1))
+	var wg sync.WaitGroup
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			w.printSummary(time.Now())
+			w.logger.Info("watch stopped")
+			return nil
+
+		case <-pingTicker.C:
+			select {
+			case semaphore <- struct{}{}:
+			default:
+				// All slots are busy with slow requests, skip this tick
+				// instead of queueing an unbounded backlog
+				w.logger.Debug("skipping ping, concurrency limit reached")
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				w.pingOnce(ctx)
+			}()
+
+		case now := <-checkTicker.C:
+			w.checkNodes(now)
+
+		case now := <-summaryTicker.C:
+			w.printSummary(now)
+		}
+	}
+}
+
+// pingOnce sends a single ping and records its outcome
+func (w *watcher) pingOnce(ctx context.Context) {
+	reqNum := int(w.requests.Add(1))
+	start := time.Now()
+	hostname, proto, err := sender.Send(ctx, w.client, &w.cfg.Config, w.cfg.Endpoint(reqNum))
+	now := time.Now()
+
+	// Requests cut short by shutdown say nothing about the deployment
+	if ctx.Err() != nil {
+		return
+	}
+
+	entry := sender.TimelineEntry{
+		Request:  reqNum,
+		Start:    start,
+		End:      now,
+		Hostname: hostname,
+		Protocol: proto,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	for _, o := range w.observers {
+		o.Observe(entry)
+	}
+
+	sample := watchSample{
+		at:       now,
+		hostname: hostname,
+		latency:  now.Sub(start).Milliseconds(),
+		failed:   err != nil,
+	}
+	if err != nil {
+		sample.hostname = sender.FailedNode(err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples = append(w.samples, sample)
+
+	if err != nil {
+		w.logger.Debug("request failed", "error", err)
+		return
+	}
+
+	lastSeen, known := w.lastSeen[hostname]
+	w.lastSeen[hostname] = now
+
+	switch {
+	case !known:
+		w.event(now, "node_appeared", "hostname", hostname)
+	case w.gone[hostname]:
+		delete(w.gone, hostname)
+		w.event(now, "node_reappeared", "hostname", hostname, "absent_for", now.Sub(lastSeen))
+	}
+}
+
+// checkNodes reports nodes that stopped answering and nodes whose error
+// rate crossed the threshold, failures no node answered are tracked as
+// sender.UnknownNode
+func (w *watcher) checkNodes(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune(now)
+
+	hostnames := make([]string, 0, len(w.lastSeen))
+	for hostname := range w.lastSeen {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	for _, hostname := range hostnames {
+		lastSeen := w.lastSeen[hostname]
+		if !w.gone[hostname] && now.Sub(lastSeen) > w.cfg.NodeTimeout {
+			w.gone[hostname] = true
+			w.event(now, "node_disappeared", "hostname", hostname, "last_seen", lastSeen.Format(time.RFC3339Nano))
+		}
+	}
+
+	// The failing state is evaluated over the node timeout rather than the
+	// whole window so that it reacts as quickly as disappearances do
+	total := make(map[string]int)
+	failed := make(map[string]int)
+	for _, s := range w.samples {
+		if now.Sub(s.at) > w.cfg.NodeTimeout {
+			continue
+		}
+		total[s.hostname]++
+		if s.failed {
+			failed[s.hostname]++
+		}
+	}
+
+	// Failing nodes without recent samples have no errors left to report
+	nodes := make([]string, 0, len(total))
+	for hostname := range total {
+		nodes = append(nodes, hostname)
+	}
+	for hostname := range w.failing {
+		if total[hostname] == 0 {
+			nodes = append(nodes, hostname)
+		}
+	}
+	sort.Strings(nodes)
+
+	for _, hostname := range nodes {
+		var errorRate float64
+		if total[hostname] > 0 {
+			errorRate = float64(failed[hostname]) / float64(total[hostname])
+		}
+
+		since, failing := w.failing[hostname]
+		switch {
+		case !failing && errorRate >= w.cfg.ErrorThreshold:
+			w.failing[hostname] = now
+			w.event(now, "failing_started", "hostname", hostname, "error_rate", fmt.Sprintf("%.1f%%", errorRate*100))
+		case failing && errorRate < w.cfg.ErrorThreshold:
+			delete(w.failing, hostname)
+			w.event(now, "failing_stopped", "hostname", hostname, "failing_for", now.Sub(since))
+		}
+	}
+}
+
+// printSummary prints statistics about the pings in the rolling window
+func (w *watcher) printSummary(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune(now)
+
+	requestsPerNode := make(map[string]int)
+	latencies := make([]int64, 0, len(w.samples))
+	var failed int
+
+	for _, s := range w.samples {
+		if s.failed {
+			failed++
+			continue
+		}
+		requestsPerNode[s.hostname]++
+		latencies = append(latencies, s.latency)
+	}
+
+	hostnames := make([]string, 0, len(requestsPerNode))
+	for hostname := range requestsPerNode {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var errorRate float64
+	if len(w.samples) > 0 {
+		errorRate = float64(failed) / float64(len(w.samples)) * 100
+	}
+
+	fmt.Fprintf(w.out, "\n=== Watch Summary %s (last %s) ===\n", now.Format(time.RFC3339), w.cfg.WatchWindow)
+	fmt.Fprintf(w.out, "Requests: %d, Failed: %d (%.1f%%), p99: %d ms\n",
+		len(w.samples), failed, errorRate, percentile(latencies, 99))
+	fmt.Fprintf(w.out, "Nodes Seen: %d\n", len(hostnames))
+	for _, hostname := range hostnames {
+		count := requestsPerNode[hostname]
+		share := float64(count) / float64(len(latencies)) * 100
+		fmt.Fprintf(w.out, "%-20s: %4d requests (%.1f%%)\n", hostname, count, share)
+	}
+}
+
+// prune drops samples that fell out of the rolling window, the caller must
+// hold the lock. Concurrent pings append their samples out of order, so
+// every sample is checked.
+func (w *watcher) prune(now time.Time) {
+	cutoff := now.Add(-w.cfg.WatchWindow)
+
+	kept := w.samples[:0]
+	for _, s := range w.samples {
+		if !s.at.Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	w.samples = kept
+}
+
+// event prints a timestamped node or deployment event as key=value pairs
+func (w *watcher) event(at time.Time, kind string, attrs ...any) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s EVENT %s", at.Format(time.RFC3339Nano), kind)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", attrs[i], attrs[i+1])
+	}
+	fmt.Fprintln(w.out, b.String())
+}
+
+// percentile returns the p-th percentile of values using the nearest-rank method
+func percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
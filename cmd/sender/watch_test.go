@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+)
+
+// nodeState is the node answering the test server and whether it fails
+type nodeState struct {
+	hostname string
+	failing  bool
+}
+
+// pingServer answers pings as the node it is set to, failing nodes answer
+// with 503 and name themselves in X-Served-By when they have a hostname
+func pingServer(t *testing.T, node *atomic.Value) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := node.Load().(nodeState)
+		name := state.hostname
+		if state.failing {
+			if name != "" {
+				w.Header().Set(sender.ServedByHeader, name)
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "pong", "hostname": name})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestWatcher returns a watcher of server that writes to out
+func newTestWatcher(server *httptest.Server, out *bytes.Buffer) *watcher {
+	return &watcher{
+		logger: slog.New(slog.DiscardHandler),
+		out:    out,
+		client: server.Client(),
+		cfg: &SenderConfig{
+			Config: sender.Config{
+				LoadBalancerURL: server.URL,
+				Concurrency:     4,
+			},
+			WatchRate:      100,
+			WatchInterval:  50 * time.Millisecond,
+			WatchWindow:    time.Minute,
+			NodeTimeout:    time.Minute,
+			ErrorThreshold: 0.5,
+		},
+		lastSeen: make(map[string]time.Time),
+		gone:     make(map[string]bool),
+		failing:  make(map[string]time.Time),
+	}
+}
+
+// events returns the kind and attributes of the events in out, without
+// their timestamps
+func events(out string) []string {
+	var events []string
+	for _, line := range strings.Split(out, "\n") {
+		if _, event, ok := strings.Cut(line, " EVENT "); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestWatcher_NodeChanges(t *testing.T) {
+	var node atomic.Value
+	server := pingServer(t, &node)
+	var out bytes.Buffer
+	w := newTestWatcher(server, &out)
+	ctx := context.Background()
+
+	send := func(state nodeState, times int) {
+		node.Store(state)
+		for range times {
+			w.pingOnce(ctx)
+		}
+	}
+	ping := func(name string, times int) {
+		send(nodeState{hostname: name}, times)
+	}
+	fail := func(name string, times int) {
+		send(nodeState{hostname: name, failing: true}, times)
+	}
+
+	ping("node-a", 2)
+	ping("node-b", 2)
+	w.checkNodes(time.Now())
+
+	// node-a was last seen longer ago than the node timeout
+	w.lastSeen["node-a"] = time.Now().Add(-2 * w.cfg.NodeTimeout)
+	w.checkNodes(time.Now())
+	w.checkNodes(time.Now())
+	ping("node-a", 1)
+
+	// Three failures out of five pings push node-b over the threshold, the
+	// failures no node answered are tracked on their own
+	fail("node-b", 3)
+	fail("", 2)
+	w.checkNodes(time.Now())
+
+	// node-b recovers, the unidentified failures are still in the window
+	ping("node-b", 4)
+	w.checkNodes(time.Now())
+
+	want := []string{
+		"node_appeared hostname=node-a",
+		"node_appeared hostname=node-b",
+		"node_disappeared hostname=node-a",
+		"node_reappeared hostname=node-a",
+		"failing_started hostname=node-b error_rate=60.0%",
+		"failing_started hostname=unknown error_rate=100.0%",
+		"failing_stopped hostname=node-b",
+	}
+	got := events(out.String())
+	if len(got) != len(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("event %d = %q, want %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestWatcher_Prune(t *testing.T) {
+	now := time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC)
+	w := &watcher{cfg: &SenderConfig{WatchWindow: time.Second}}
+
+	// Concurrent pings finish out of order
+	w.samples = []watchSample{
+		{at: now, hostname: "node-a"},
+		{at: now.Add(-2 * time.Second), hostname: "old"},
+		{at: now.Add(-500 * time.Millisecond), hostname: "node-b"},
+		{at: now.Add(-1500 * time.Millisecond), hostname: "old"},
+	}
+	w.prune(now)
+
+	if len(w.samples) != 2 || w.samples[0].hostname != "node-a" || w.samples[1].hostname != "node-b" {
+		t.Errorf("samples = %+v, want node-a and node-b in order", w.samples)
+	}
+}
+
+// countingObserver counts the requests it observes
+type countingObserver struct {
+	mu      sync.Mutex
+	entries int
+}
+
+func (c *countingObserver) Observe(sender.TimelineEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries++
+}
+
+func TestWatcher_Run(t *testing.T) {
+	var node atomic.Value
+	node.Store(nodeState{hostname: "node-a"})
+	server := pingServer(t, &node)
+	var out bytes.Buffer
+	w := newTestWatcher(server, &out)
+	observer := &countingObserver{}
+	w.observers = []sender.Observer{observer}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := w.run(ctx); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	got := out.String()
+	if events := events(got); len(events) == 0 || events[0] != "node_appeared hostname=node-a" {
+		t.Errorf("events = %q, want node_appeared hostname=node-a first", events)
+	}
+	// One summary per interval and a last one on shutdown
+	if n := strings.Count(got, "=== Watch Summary"); n < 2 {
+		t.Errorf("printed %d summaries, want at least 2", n)
+	}
+	if !strings.Contains(got, "node-a") || !strings.Contains(got, "Nodes Seen: 1") {
+		t.Errorf("summary does not list node-a:\n%s", got)
+	}
+	if observer.entries == 0 {
+		t.Error("observers were not notified")
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/sender"
+	"github.com/ihatemodels/alcatraz-rest/internal/websocket"
+)
+
+// WSConnection describes a single WebSocket connection of the test
+type WSConnection struct {
+	Connection  int     `json:"connection"`
+	Hostname    string  `json:"hostname,omitempty"`
+	HandshakeMs float64 `json:"handshake_ms"`
+	Messages    int     `json:"messages"`
+	Pongs       int     `json:"pongs"`
+	// Switches counts replies from another node than the first one, an
+	// upgraded connection must stay on its node
+	Switches int    `json:"switches"`
+	Error    string `json:"error,omitempty"`
+}
+
+// WSReport holds the results of a WebSocket test run
+type WSReport struct {
+	Connections           int                         `json:"connections"`
+	Established           int                         `json:"established"`
+	Failed                int                         `json:"failed"`
+	MessagesPerConnection int                         `json:"messages_per_connection"`
+	Messages              int                         `json:"messages"`
+	MissingPongs          int                         `json:"missing_pongs"`
+	NodeSwitches          int                         `json:"node_switches"`
+	ConnectionsPerNode    map[string]int              `json:"connections_per_node"`
+	Stats                 *sender.LoadBalancerSummary `json:"stats"`
+	PerConnection         []WSConnection              `json:"per_connection"`
+}
+
+// runWebSocket opens cfg.WSConnections WebSocket connections through the
+// load balancer and keeps them all open until every connection exchanged
+// its messages, so the balancer distributes concurrent upgraded connections
+func runWebSocket(ctx context.Context, logger *slog.Logger, cfg *SenderConfig) error {
+	if cfg.Concurrency < 1 {
+		return fmt.Errorf("invalid concurrency: %d (must be at least 1)", cfg.Concurrency)
+	}
+	if cfg.WSMessages < 1 {
+		return fmt.Errorf("invalid messages per connection: %d (must be at least 1)", cfg.WSMessages)
+	}
+	if cfg.Protocol == sender.ProtocolHTTP2 || cfg.Protocol == sender.ProtocolH2C {
+		return fmt.Errorf("WebSocket connections need HTTP/1.1, use -proto %s or %s", sender.ProtocolAuto, sender.ProtocolHTTP1)
+	}
+
+	// Upgrades only work over HTTP/1.1, keep ALPN from choosing HTTP/2
+	transport, ok := cfg.Client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unsupported HTTP transport %T", cfg.Client.Transport)
+	}
+	transport = transport.Clone()
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetHTTP1(true)
+	client := &http.Client{Transport: transport}
+
+	url := cfg.LoadBalancerURL + cfg.WSPath
+	header := make(http.Header)
+	for name, value := range cfg.Endpoint(1).Headers {
+		header.Set(name, value)
+	}
+
+	logger.Info("starting WebSocket test",
+		"url", url,
+		"connections", cfg.WSConnections,
+		"messages_per_connection", cfg.WSMessages,
+		"concurrency", cfg.Concurrency)
+
+	ids := make(chan int)
+	go func() {
+		defer close(ids)
+		for id := 1; id <= cfg.WSConnections && ctx.Err() == nil; id++ {
+			select {
+			case ids <- id:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	connections := make([]WSConnection, 0, cfg.WSConnections)
+	var open []*websocket.Conn
+	stats := sender.NewLoadBalancerStats()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for id := range ids {
+				result, conn, proto, err := runWSConnection(ctx, client, url, header, cfg.Timeout, cfg.WSMessages, id)
+				// Connections cut by the interruption say nothing about the nodes
+				if ctx.Err() != nil {
+					if conn != nil {
+						_ = conn.Close()
+					}
+					return
+				}
+
+				mu.Lock()
+				stats.Record(result.Hostname, proto, int64(result.HandshakeMs), err)
+				connections = append(connections, result)
+				if conn != nil {
+					open = append(open, conn)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, conn := range open {
+		_ = conn.WriteClose(websocket.CloseNormal, "test finished")
+		_ = conn.Close()
+	}
+
+	stats.Interrupted = ctx.Err() != nil
+	stats.Finalize()
+
+	report := buildWSReport(connections, cfg.WSMessages)
+	report.Stats = stats.Summary()
+
+	sender.WriteStats(os.Stdout, stats)
+	displayWebSocket(logger, report)
+
+	return nil
+}
+
+// runWSConnection opens a connection and sends the messages, each preceded
+// by a ping frame, the connection is returned open unless it failed
+func runWSConnection(ctx context.Context, client *http.Client, url string, header http.Header, timeout time.Duration, messages, id int) (WSConnection, *websocket.Conn, string, error) {
+	result := WSConnection{Connection: id}
+
+	start := time.Now()
+	conn, resp, err := websocket.Dial(ctx, client, url, header, timeout)
+	result.HandshakeMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil, "", err
+	}
+
+	pongs := make(map[string]bool)
+	conn.OnPong = func(payload []byte) {
+		pongs[string(payload)] = true
+	}
+
+	for i := 1; i <= messages; i++ {
+		payload := strconv.Itoa(i)
+		if err := conn.WriteMessage(websocket.PingMessage, []byte(payload)); err != nil {
+			return failWS(result, conn, err)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("ping "+payload)); err != nil {
+			return failWS(result, conn, err)
+		}
+
+		// Reads cannot be cancelled, closing the connection ends them
+		timer := time.AfterFunc(timeout, func() { _ = conn.Close() })
+		opcode, message, err := conn.ReadMessage()
+		timedOut := !timer.Stop()
+		if timedOut {
+			err = errors.New("no reply within the timeout")
+		}
+		if err != nil {
+			return failWS(result, conn, err)
+		}
+
+		var reply apiv2.WSReply
+		if opcode != websocket.TextMessage || json.Unmarshal(message, &reply) != nil || reply.Hostname == "" {
+			return failWS(result, conn, fmt.Errorf("unexpected reply: %q", message))
+		}
+
+		result.Messages++
+		if result.Hostname == "" {
+			result.Hostname = reply.Hostname
+		} else if reply.Hostname != result.Hostname {
+			result.Switches++
+		}
+		// The pong arrives before the reply to the following message
+		if pongs[payload] {
+			result.Pongs++
+		}
+	}
+
+	return result, conn, resp.Proto, nil
+}
+
+func failWS(result WSConnection, conn *websocket.Conn, err error) (WSConnection, *websocket.Conn, string, error) {
+	_ = conn.Close()
+	result.Error = err.Error()
+	return result, nil, "", err
+}
+
+// buildWSReport aggregates the per-connection results
+func buildWSReport(connections []WSConnection, messagesPerConnection int) *WSReport {
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].Connection < connections[j].Connection
+	})
+
+	report := &WSReport{
+		Connections:           len(connections),
+		MessagesPerConnection: messagesPerConnection,
+		ConnectionsPerNode:    make(map[string]int),
+		PerConnection:         connections,
+	}
+
+	for _, c := range connections {
+		report.Messages += c.Messages
+		report.MissingPongs += c.Messages - c.Pongs
+		report.NodeSwitches += c.Switches
+
+		if c.Error != "" {
+			report.Failed++
+			continue
+		}
+		report.Established++
+		report.ConnectionsPerNode[c.Hostname]++
+	}
+
+	return report
+}
+
+// displayWebSocket prints the WebSocket results
+func displayWebSocket(logger *slog.Logger, report *WSReport) {
+	fmt.Println("\n=== WebSocket Connections ===")
+	fmt.Printf("Connections: %d, Established: %d, Failed: %d\n", report.Connections, report.Established, report.Failed)
+	fmt.Printf("Messages: %d (%d per connection), Missing Pongs: %d\n",
+		report.Messages, report.MessagesPerConnection, report.MissingPongs)
+	fmt.Printf("Node Switches Within A Connection: %d\n", report.NodeSwitches)
+
+	fmt.Println("\n=== Connections Per Node ===")
+	nodes := make([]string, 0, len(report.ConnectionsPerNode))
+	for hostname := range report.ConnectionsPerNode {
+		nodes = append(nodes, hostname)
+	}
+	sort.Strings(nodes)
+	for _, hostname := range nodes {
+		count := report.ConnectionsPerNode[hostname]
+		fmt.Printf("%-20s: %4d connections (%.1f%%)\n", hostname, count, float64(count)/float64(max(report.Established, 1))*100)
+	}
+
+	var failed int
+	for _, c := range report.PerConnection {
+		if c.Error == "" {
+			continue
+		}
+		if failed == 0 {
+			fmt.Println("\n=== Failed Connections ===")
+		}
+		failed++
+		fmt.Printf("connection %-5d: %s\n", c.Connection, c.Error)
+	}
+
+	fmt.Println("\n=== JSON Output ===")
+	jsonOutput, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error("failed to marshal WebSocket report to JSON", "error", err)
+	} else {
+		fmt.Println(string(jsonOutput))
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildWSReport(t *testing.T) {
+	connections := []WSConnection{
+		{Connection: 3, Hostname: "node-b", Messages: 5, Pongs: 5},
+		{Connection: 1, Hostname: "node-a", Messages: 5, Pongs: 4},
+		{Connection: 2, Hostname: "node-a", Messages: 5, Pongs: 5, Switches: 1},
+		{Connection: 4, Error: "unexpected status code: 502"},
+	}
+
+	report := buildWSReport(connections, 5)
+
+	if report.Connections != 4 || report.Established != 3 || report.Failed != 1 {
+		t.Errorf("connections, established, failed = %d, %d, %d, want 4, 3, 1",
+			report.Connections, report.Established, report.Failed)
+	}
+	if report.Messages != 15 {
+		t.Errorf("Messages = %d, want 15", report.Messages)
+	}
+	if report.MissingPongs != 1 {
+		t.Errorf("MissingPongs = %d, want 1", report.MissingPongs)
+	}
+	if report.NodeSwitches != 1 {
+		t.Errorf("NodeSwitches = %d, want 1", report.NodeSwitches)
+	}
+	if report.ConnectionsPerNode["node-a"] != 2 || report.ConnectionsPerNode["node-b"] != 1 || len(report.ConnectionsPerNode) != 2 {
+		t.Errorf("ConnectionsPerNode = %v, want node-a: 2, node-b: 1", report.ConnectionsPerNode)
+	}
+	if report.PerConnection[0].Connection != 1 {
+		t.Errorf("PerConnection[0].Connection = %d, want connections sorted by id", report.PerConnection[0].Connection)
+	}
+}
@@ -1,22 +1,32 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	api "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/capture"
 	"github.com/ihatemodels/alcatraz-rest/internal/config"
+	"github.com/ihatemodels/alcatraz-rest/internal/fault"
 	"github.com/ihatemodels/alcatraz-rest/internal/observability"
+	"github.com/ihatemodels/alcatraz-rest/internal/rpc"
 )
 
 var version string
 
+// shutdownTimeout is how long requests may take to finish on SIGTERM
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -40,19 +50,120 @@ func main() {
 			"require_client_cert", cfg.Server.TLS.RequireClientCert)
 	}
 
+	node := apiv2.NewNode(version, cfg.GetServerAddress(), tlsMode(cfg))
+	node.Metadata = apiv2.NodeMetadata{
+		Name:   cfg.Node.Name,
+		Zone:   cfg.Node.Zone,
+		Region: cfg.Node.Region,
+		Labels: cfg.Node.Labels,
+	}
+
+	// The echo endpoint exposes request internals, it is opt-in
+	var echo *apiv2.EchoOptions
+	if cfg.Server.Echo.Enabled {
+		// Validated with the configuration
+		proxies, _ := cfg.Server.Echo.Proxies()
+		echo = &apiv2.EchoOptions{
+			RedactHeaders:  cfg.Server.Echo.RedactHeaders,
+			TrustedProxies: proxies,
+		}
+		logger.Warn("echo endpoint enabled, it exposes request headers and TLS details")
+	}
+
+	// Fault injection makes the node misbehave on purpose, it is opt-in
+	var faults *fault.Injector
+	if cfg.Faults.Enabled {
+		faults = fault.NewInjector(cfg.Faults.AdminToken)
+		logger.Warn("fault injection enabled", "admin_api", fault.AdminPath)
+	}
+
+	// Every error, including the ones of the middleware, is a problem
+	var handler http.Handler = newRouter(node, version, routerOptions{Echo: echo, Faults: faults})
+	if faults != nil {
+		handler = faults.Middleware(handler)
+	}
+	handler = api.Recover(handler)
+
+	// Capture requests for replay with the sender if enabled
+	var captureWriter *capture.Writer
+	if cfg.Capture.Enabled {
+		captureWriter, err = capture.Create(cfg.Capture.File)
+		if err != nil {
+			logger.Error("failed to configure request capture", "error", err)
+			os.Exit(1)
+		}
+		handler = capture.Middleware(captureWriter, logger, handler)
+		logger.Info("request capture enabled", "file", cfg.Capture.File)
+	}
+	handler = node.WithHeaders(apiv2.ResponseHeaders{
+		ServedBy:     cfg.Server.Headers.ServedBy,
+		NodeVersion:  cfg.Server.Headers.NodeVersion,
+		ServerTiming: cfg.Server.Headers.ServerTiming,
+	}, node.Identify(node.Track(node.RejectDraining(handler))))
+	handler = api.WithRequestID(handler)
+
 	srv := &http.Server{
 		Addr:      cfg.GetServerAddress(),
-		Handler:   nil,
+		Handler:   handler,
 		TLSConfig: tlsConfig,
 	}
 
-	http.HandleFunc("/api/ping", api.PingHandler)
+	// The gRPC API shares the TLS configuration of the REST API
+	var grpcServer *rpc.Server
+	if cfg.Server.GRPC.Enabled {
+		grpcServer, err = newGRPCServer(cfg, node, tlsConfig)
+		if err != nil {
+			logger.Error("failed to configure gRPC server", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	term := make(chan os.Signal, 1)
-	srvClose := make(chan struct{})
-
 	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
 
+	srvErr := serve(logger, cfg, srv, grpcServer)
+
+	for {
+		select {
+		case <-term:
+			logger.Info("Received SIGTERM, exiting gracefully...", "in_flight", node.InFlight(), "timeout", shutdownTimeout.String())
+
+			// Streams and WebSockets end when the node drains, then wait for the other requests
+			node.Drain()
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			if grpcServer != nil {
+				if err := grpcServer.Shutdown(ctx); err != nil {
+					logger.Error("failed to finish gRPC calls before shutdown", "error", err)
+				}
+			}
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Error("failed to finish requests before shutdown", "error", err, "in_flight", node.InFlight())
+				_ = srv.Close()
+			}
+			// Shutdown does not wait for hijacked WebSocket connections
+			for node.InFlight() > 0 && ctx.Err() == nil {
+				time.Sleep(50 * time.Millisecond)
+			}
+			cancel()
+
+			if captureWriter != nil {
+				if err := captureWriter.Close(); err != nil {
+					logger.Error("failed to close capture file", "error", err)
+				}
+			}
+			os.Exit(0)
+		case <-srvErr:
+			os.Exit(1)
+		}
+	}
+}
+
+// serve starts the REST server and the gRPC server when configured, their
+// failures are sent on the returned channel, which has room for both so
+// neither goroutine blocks when both listeners fail
+func serve(logger *slog.Logger, cfg *config.Config, srv *http.Server, grpcServer *rpc.Server) <-chan error {
+	errs := make(chan error, 2)
+
 	go func() {
 		var err error
 		if cfg.Server.TLS.Enabled {
@@ -65,18 +176,53 @@ func main() {
 
 		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server failed", "error", err)
-			close(srvClose)
+			errs <- err
 		}
 	}()
 
-	for {
-		select {
-		case <-term:
-			logger.Info("Received SIGTERM, exiting gracefully...")
-			os.Exit(0)
-		case <-srvClose:
-			os.Exit(1)
-		}
+	if grpcServer != nil {
+		go func() {
+			lis, err := net.Listen("tcp", cfg.GetGRPCAddress())
+			if err == nil {
+				logger.Info("starting gRPC server", "address", cfg.GetGRPCAddress(), "tls", cfg.Server.TLS.Enabled, "reflection", cfg.Server.GRPC.Reflection)
+				err = grpcServer.Serve(lis)
+			}
+			if err != nil {
+				logger.Error("gRPC server failed", "error", err)
+				errs <- err
+			}
+		}()
+	}
+
+	return errs
+}
+
+// newGRPCServer creates the gRPC server, the certificate is loaded here
+// because the REST server loads it when it starts listening
+func newGRPCServer(cfg *config.Config, node *apiv2.Node, tlsConfig *tls.Config) (*rpc.Server, error) {
+	if tlsConfig == nil {
+		return rpc.NewServer(node, nil, cfg.Server.GRPC.Reflection), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	grpcTLS := tlsConfig.Clone()
+	grpcTLS.Certificates = []tls.Certificate{cert}
+
+	return rpc.NewServer(node, grpcTLS, cfg.Server.GRPC.Reflection), nil
+}
+
+// tlsMode returns how the server accepts connections
+func tlsMode(cfg *config.Config) apiv2.TLSMode {
+	switch {
+	case !cfg.Server.TLS.Enabled:
+		return apiv2.TLSModeDisabled
+	case cfg.Server.TLS.RequireClientCert:
+		return apiv2.TLSModeMutual
+	default:
+		return apiv2.TLSModeTLS
 	}
 }
 
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/config"
+	"github.com/ihatemodels/alcatraz-rest/internal/rpc"
+)
+
+// listen occupies a free local port for the duration of the test
+func listen(t *testing.T) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		lis.Close()
+	})
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func TestServe_BothListenersFail(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			ListenAddress: "127.0.0.1",
+			Port:          listen(t),
+			GRPC: config.GRPCConfig{
+				Enabled: true,
+				Port:    listen(t),
+			},
+		},
+	}
+	srv := &http.Server{Addr: cfg.GetServerAddress()}
+	grpcServer := rpc.NewServer(apiv2.NewNode("test", cfg.GetServerAddress(), apiv2.TLSModeDisabled), nil, false)
+
+	errs := serve(slog.New(slog.DiscardHandler), cfg, srv, grpcServer)
+
+	for i := range 2 {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Errorf("error %d = nil, want a listen error", i+1)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d errors, want 2", i)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	apiv1 "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/fault"
+)
+
+// routerOptions holds the opt-in parts of the API, nil leaves them out
+type routerOptions struct {
+	// Echo exposes request internals
+	Echo *apiv2.EchoOptions
+	// Faults lets the node misbehave on purpose
+	Faults *fault.Injector
+}
+
+// newRouter registers the routes of every API version, the OpenAPI
+// document is generated from them
+func newRouter(node *apiv2.Node, version string, opts routerOptions) *api.Router {
+	if version == "" {
+		version = "dev"
+	}
+	router := api.NewRouter(api.Info{Title: "Alcatraz REST API", Version: version})
+
+	// v1 stays unchanged, new fields are only added to v2
+	router.Handle(apiv1.Routes(node)...)
+	router.Handle(apiv2.Routes(node)...)
+	router.Handle(apiv2.StreamRoutes(node)...)
+	router.Handle(apiv2.WSRoutes(node)...)
+	if opts.Echo != nil {
+		router.Handle(apiv2.EchoRoutes(node, *opts.Echo)...)
+	}
+	if opts.Faults != nil {
+		router.Handle(api.Route{
+			Method:      http.MethodGet,
+			Path:        "/api/v2/fault",
+			OperationID: "faultV2",
+			Summary:     "The v2 ping response with the fault described by the query parameters",
+			Parameters:  fault.Parameters(),
+			Handler:     fault.Handler(apiv2.PingHandler(node)),
+			Response:    apiv2.PingResponse{},
+		})
+		router.Handle(opts.Faults.Routes()...)
+	}
+
+	return router
+}
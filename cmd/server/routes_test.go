@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/fault"
+)
+
+// TestOpenAPI_Drift calls every documented read operation with every
+// documented media type and validates the responses against the OpenAPI document
+func TestOpenAPI_Drift(t *testing.T) {
+	router := newRouter(apiv2.NewNode("test", "127.0.0.1:8080", apiv2.TLSModeDisabled), "test", routerOptions{
+		Echo:   &apiv2.EchoOptions{},
+		Faults: fault.NewInjector("test-token"),
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var doc api.OpenAPI
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode OpenAPI document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Info.Version != "test" {
+		t.Errorf("openapi, version = %q, %q, want 3.1.0, test", doc.OpenAPI, doc.Info.Version)
+	}
+
+	documented := 0
+	for _, methods := range doc.Paths {
+		documented += len(methods)
+	}
+	if documented != len(router.Routes()) {
+		t.Errorf("document has %d operations, the router %d routes", documented, len(router.Routes()))
+	}
+
+	for _, route := range router.Routes() {
+		operation, ok := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", route.Method, route.Path)
+			continue
+		}
+		// Operations that change the node need parameters, only read
+		if route.Method != http.MethodGet {
+			continue
+		}
+
+		for mediaType, content := range operation.Responses["200"].Content {
+			t.Run(route.Method+" "+route.Path+" "+mediaType, func(t *testing.T) {
+				req := httptest.NewRequest(route.Method, route.Path, nil)
+				req.Header.Set("Accept", mediaType)
+				req.Header.Set("Authorization", "Bearer test-token")
+				if mediaType == "text/event-stream" {
+					// Streams run until the client leaves, the first event is enough
+					ctx, cancel := context.WithTimeout(req.Context(), 50*time.Millisecond)
+					defer cancel()
+					req = req.WithContext(ctx)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+				}
+				got, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+				if err != nil || got != mediaType {
+					t.Fatalf("Content-Type = %q, want %s", rec.Header().Get("Content-Type"), mediaType)
+				}
+
+				// JSON and CBOR share the JSON data model the schemas describe
+				var body any
+				switch mediaType {
+				case "application/json":
+					err = json.NewDecoder(rec.Body).Decode(&body)
+				case "application/cbor":
+					var data []byte
+					if data, err = io.ReadAll(rec.Body); err == nil {
+						err = api.UnmarshalCBOR(data, &body)
+					}
+				case "text/event-stream":
+					err = decodeEvent(rec.Body, &body)
+				default:
+					return
+				}
+				if err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				validate(t, doc.Components.Schemas, content.Schema, body, "response")
+			})
+		}
+	}
+}
+
+// validate fails the test when value does not match the schema, fields
+// missing from the schema count as drift as well
+func validate(t *testing.T, schemas map[string]api.Schema, schema api.Schema, value any, at string) {
+	t.Helper()
+
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		resolved, ok := schemas[name]
+		if !ok {
+			t.Errorf("%s: unknown schema %s", at, ref)
+			return
+		}
+		schema = resolved
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: got %T, want object", at, value)
+			return
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				t.Errorf("%s: required field %s is missing", at, name)
+			}
+		}
+		for name, field := range object {
+			if property, ok := properties[name]; ok {
+				validate(t, schemas, asSchema(property), field, at+"."+name)
+				continue
+			}
+			if additional, ok := schema["additionalProperties"]; ok {
+				validate(t, schemas, asSchema(additional), field, at+"."+name)
+				continue
+			}
+			if properties != nil {
+				t.Errorf("%s: field %s is not documented", at, name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			t.Errorf("%s: got %T, want array", at, value)
+			return
+		}
+		for _, item := range items {
+			validate(t, schemas, asSchema(schema["items"]), item, at+"[]")
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			t.Errorf("%s: got %T, want string", at, value)
+			return
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				t.Errorf("%s: %q is not a date-time", at, s)
+			}
+		}
+		if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, any(s)) {
+			t.Errorf("%s: %q is not one of %v", at, s, enum)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			t.Errorf("%s: got %v, want integer", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: got %T, want number", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: got %T, want boolean", at, value)
+		}
+	}
+}
+
+// decodeEvent decodes the JSON data of the first server-sent event
+func decodeEvent(r io.Reader, v any) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			return json.Unmarshal([]byte(data), v)
+		}
+	}
+	return errors.New("no event with data")
+}
+
+// asSchema converts a schema decoded into a plain map
+func asSchema(v any) api.Schema {
+	m, _ := v.(map[string]any)
+	return api.Schema(m)
+}
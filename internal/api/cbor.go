@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// CBOR (RFC 8949) major types
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// maxCBORLength limits the length of decoded strings, arrays and maps
+const maxCBORLength = 1 << 20
+
+// maxCBORDepth limits the nesting of decoded arrays and maps
+const maxCBORDepth = 32
+
+// MarshalCBOR encodes v as CBOR, v is encoded as its JSON data model so the
+// field names and omissions match the JSON representation
+func MarshalCBOR(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes CBOR into v through its JSON data model
+func UnmarshalCBOR(data []byte, v any) error {
+	r := bytes.NewReader(data)
+	generic, err := decodeCBOR(r, 0)
+	if err != nil {
+		return fmt.Errorf("failed to decode CBOR: %w", err)
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("failed to decode CBOR: %d trailing bytes", r.Len())
+	}
+
+	jsonData, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+// encodeCBOR encodes the values produced by decoding JSON with UseNumber
+func encodeCBOR(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | 22)
+	case bool:
+		if v {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n >= 0 {
+				writeCBORHead(buf, cborUnsigned, uint64(n))
+			} else {
+				writeCBORHead(buf, cborNegative, uint64(-1-n))
+			}
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(cborSimple<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case []any:
+		writeCBORHead(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		// Sorted keys keep the encoding deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeCBORHead(buf, cborMap, uint64(len(v)))
+		for _, key := range keys {
+			writeCBORHead(buf, cborText, uint64(len(key)))
+			buf.WriteString(key)
+			if err := encodeCBOR(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as CBOR", v)
+	}
+	return nil
+}
+
+// writeCBORHead writes the initial byte and argument of a data item
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// decodeCBOR decodes a data item nested depth levels deep into the types
+// encoding/json produces with UseNumber, indefinite lengths and tags are
+// not supported
+func decodeCBOR(r *bytes.Reader, depth int) (any, error) {
+	initial, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial>>5, initial&0x1f
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			var bits uint16
+			if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+				return nil, err
+			}
+			return float16ToFloat64(bits), nil
+		case 26:
+			var bits uint32
+			if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(bits)), nil
+		case 27:
+			var bits uint64
+			if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(bits), nil
+		default:
+			return nil, fmt.Errorf("unsupported simple value %d", info)
+		}
+	}
+
+	n, err := readCBORArgument(r, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case cborNegative:
+		// -1-n does not fit an int64 for the largest arguments
+		return json.Number(new(big.Int).Sub(big.NewInt(-1), new(big.Int).SetUint64(n)).String()), nil
+	case cborBytes, cborText:
+		if n > maxCBORLength || n > uint64(r.Len()) {
+			return nil, fmt.Errorf("string of %d bytes exceeds the input", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case cborArray:
+		// Every item takes at least one byte
+		if n > maxCBORLength || n > uint64(r.Len()) {
+			return nil, fmt.Errorf("array of %d items exceeds the input", n)
+		}
+		if depth >= maxCBORDepth {
+			return nil, fmt.Errorf("arrays and maps are nested deeper than %d levels", maxCBORDepth)
+		}
+		items := make([]any, 0, min(n, 1024))
+		for range n {
+			item, err := decodeCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		// Every entry takes at least two bytes
+		if n > maxCBORLength || n > uint64(r.Len())/2 {
+			return nil, fmt.Errorf("map of %d entries exceeds the input", n)
+		}
+		if depth >= maxCBORDepth {
+			return nil, fmt.Errorf("arrays and maps are nested deeper than %d levels", maxCBORDepth)
+		}
+		entries := make(map[string]any, min(n, 1024))
+		for range n {
+			key, err := decodeCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, errors.New("map keys must be strings")
+			}
+			if entries[name], err = decodeCBOR(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("unsupported major type %d", major)
+	}
+}
+
+// readCBORArgument reads the argument that follows the initial byte
+func readCBORArgument(r *bytes.Reader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		b, err := r.ReadByte()
+		return uint64(b), err
+	case info == 25:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return uint64(n), err
+	case info == 26:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return uint64(n), err
+	case info == 27:
+		var n uint64
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	default:
+		return 0, fmt.Errorf("unsupported additional information %d", info)
+	}
+}
+
+// float16ToFloat64 converts an IEEE 754 half precision number
+func float16ToFloat64(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+
+	var value float64
+	switch exp {
+	case 0:
+		value = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if bits&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
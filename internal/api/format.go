@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a representation the API responds with
+type Format struct {
+	Name      string
+	MediaType string
+	// aliases are other media types clients use for the format
+	aliases []string
+	encode  func(w io.Writer, v any) error
+	decode  func(r io.Reader, v any) error
+}
+
+// PlainTexter is implemented by responses that have a plain text form
+type PlainTexter interface {
+	PlainText() string
+}
+
+// Formats lists the supported formats, the first one is the default
+var Formats = []Format{
+	{
+		Name:      "json",
+		MediaType: "application/json",
+		encode: func(w io.Writer, v any) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+		decode: func(r io.Reader, v any) error {
+			return json.NewDecoder(r).Decode(v)
+		},
+	},
+	{
+		Name:      "text",
+		MediaType: "text/plain",
+		encode: func(w io.Writer, v any) error {
+			texter, ok := v.(PlainTexter)
+			if !ok {
+				return fmt.Errorf("%T has no plain text form", v)
+			}
+			_, err := fmt.Fprintln(w, texter.PlainText())
+			return err
+		},
+		decode: func(r io.Reader, v any) error {
+			return errors.New("plain text responses cannot be decoded")
+		},
+	},
+	{
+		Name:      "xml",
+		MediaType: "application/xml",
+		aliases:   []string{"text/xml"},
+		encode: func(w io.Writer, v any) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			if err := xml.NewEncoder(w).Encode(v); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "\n")
+			return err
+		},
+		decode: func(r io.Reader, v any) error {
+			return xml.NewDecoder(r).Decode(v)
+		},
+	},
+	{
+		Name:      "yaml",
+		MediaType: "application/yaml",
+		aliases:   []string{"application/x-yaml", "text/yaml"},
+		encode: func(w io.Writer, v any) error {
+			encoder := yaml.NewEncoder(w)
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+			return encoder.Close()
+		},
+		decode: func(r io.Reader, v any) error {
+			return yaml.NewDecoder(r).Decode(v)
+		},
+	},
+	{
+		Name:      "cbor",
+		MediaType: "application/cbor",
+		encode: func(w io.Writer, v any) error {
+			data, err := MarshalCBOR(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		},
+		decode: func(r io.Reader, v any) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return UnmarshalCBOR(data, v)
+		},
+	},
+}
+
+// FormatByName returns the format with the given name
+func FormatByName(name string) (Format, bool) {
+	for _, format := range Formats {
+		if format.Name == name {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// FormatByMediaType returns the format of the media type, parameters such
+// as the charset are ignored
+func FormatByMediaType(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Format{}, false
+	}
+	for _, format := range Formats {
+		if format.MediaType == mediaType || slices.Contains(format.aliases, mediaType) {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// FormatNames returns the names of the supported formats
+func FormatNames() []string {
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = format.Name
+	}
+	return names
+}
+
+// ContentType is the Content-Type header of responses in the format
+func (f Format) ContentType() string {
+	if strings.HasPrefix(f.MediaType, "text/") {
+		return f.MediaType + "; charset=utf-8"
+	}
+	return f.MediaType
+}
+
+// Write sends v as the response body in the format
+func (f Format) Write(w http.ResponseWriter, v any) error {
+	w.Header().Set("Content-Type", f.ContentType())
+	w.Header().Add("Vary", "Accept")
+	return f.encode(w, v)
+}
+
+// Decode reads a value in the format from r
+func (f Format) Decode(r io.Reader, v any) error {
+	return f.decode(r, v)
+}
+
+// Negotiate picks the response format from the format query parameter or
+// the Accept header, it responds with 406 and reports false when none of
+// the supported formats is acceptable
+func Negotiate(w http.ResponseWriter, r *http.Request) (Format, bool) {
+	if format, ok := negotiate(r); ok {
+		return format, true
+	}
+
+	WriteProblem(w, r, http.StatusNotAcceptable,
+		fmt.Sprintf("supported formats are %s, choose one with the Accept header or the format query parameter",
+			strings.Join(FormatNames(), ", ")))
+	return Format{}, false
+}
+
+// NegotiateCompatible picks the response format of an endpoint that answered
+// JSON before negotiation existed. It keeps JSON unless the format query
+// parameter or an exact media type among the most preferred ranges of the
+// Accept header asks for another format, so browsers and clients that
+// never asked for JSON keep getting it
+func NegotiateCompatible(w http.ResponseWriter, r *http.Request) (Format, bool) {
+	if r.URL.Query().Get("format") != "" {
+		return Negotiate(w, r)
+	}
+
+	ranges := parseAccept(strings.Join(r.Header.Values("Accept"), ","))
+	for _, mediaRange := range ranges {
+		if mediaRange.q < ranges[0].q {
+			break
+		}
+		if mediaRange.specificity() < 2 {
+			continue
+		}
+		for _, format := range Formats {
+			if mediaRange.matches(format) {
+				return format, true
+			}
+		}
+	}
+	return Formats[0], true
+}
+
+func negotiate(r *http.Request) (Format, bool) {
+	// The query parameter is easier to use from curl and browsers
+	if name := r.URL.Query().Get("format"); name != "" {
+		return FormatByName(name)
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return Formats[0], true
+	}
+
+	for _, mediaRange := range parseAccept(strings.Join(accept, ",")) {
+		for _, format := range Formats {
+			if mediaRange.matches(format) {
+				return format, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// acceptRange is a media range of the Accept header
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity ranks exact media types before type/* before */*
+func (a acceptRange) specificity() int {
+	switch {
+	case a.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (a acceptRange) matches(f Format) bool {
+	switch a.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(f.MediaType, strings.TrimSuffix(a.mediaType, "*"))
+	default:
+		return f.MediaType == a.mediaType || slices.Contains(f.aliases, a.mediaType)
+	}
+}
+
+// parseAccept returns the acceptable media ranges of the header, the most
+// preferred first, ranges with q=0 are left out
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name       string
+		accept     string
+		query      string
+		wantFormat string
+		wantOK     bool
+	}{
+		{name: "no accept header", wantFormat: "json", wantOK: true},
+		{name: "anything", accept: "*/*", wantFormat: "json", wantOK: true},
+		{name: "plain text", accept: "text/plain", wantFormat: "text", wantOK: true},
+		{name: "xml alias", accept: "text/xml", wantFormat: "xml", wantOK: true},
+		{name: "yaml alias", accept: "application/x-yaml", wantFormat: "yaml", wantOK: true},
+		{name: "cbor", accept: "application/cbor", wantFormat: "cbor", wantOK: true},
+		{name: "highest quality wins", accept: "application/json;q=0.5, application/cbor;q=0.9", wantFormat: "cbor", wantOK: true},
+		{name: "specific before wildcard", accept: "*/*, application/yaml", wantFormat: "yaml", wantOK: true},
+		{name: "type wildcard", accept: "text/*", wantFormat: "text", wantOK: true},
+		{name: "browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", wantFormat: "xml", wantOK: true},
+		{name: "excluded type", accept: "application/cbor;q=0", wantOK: false},
+		{name: "unsupported type", accept: "image/png", wantOK: false},
+		{name: "query overrides header", accept: "application/json", query: "yaml", wantFormat: "yaml", wantOK: true},
+		{name: "unsupported query", query: "csv", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/ping"
+			if tt.query != "" {
+				target += "?format=" + tt.query
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			format, ok := Negotiate(rec, req)
+			if ok != tt.wantOK {
+				t.Fatalf("Negotiate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				if rec.Code != http.StatusNotAcceptable {
+					t.Errorf("status = %d, want %d", rec.Code, http.StatusNotAcceptable)
+				}
+				return
+			}
+			if format.Name != tt.wantFormat {
+				t.Errorf("format = %s, want %s", format.Name, tt.wantFormat)
+			}
+		})
+	}
+}
+
+// testResponse is a response with every kind of field the API uses
+type testResponse struct {
+	Message string            `json:"message" xml:"message" yaml:"message"`
+	Count   int               `json:"count" xml:"count" yaml:"count"`
+	Large   int64             `json:"large" xml:"large" yaml:"large"`
+	Ratio   float64           `json:"ratio" xml:"ratio" yaml:"ratio"`
+	Enabled bool              `json:"enabled" xml:"enabled" yaml:"enabled"`
+	Nested  *testResponse     `json:"nested,omitempty" xml:"nested,omitempty" yaml:"nested,omitempty"`
+	Tags    []string          `json:"tags" xml:"tags" yaml:"tags"`
+	Labels  map[string]string `json:"labels,omitempty" xml:"-" yaml:"labels,omitempty"`
+}
+
+func (r testResponse) PlainText() string {
+	return r.Message
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	want := testResponse{
+		Message: "pong",
+		Count:   -42,
+		Large:   1 << 40,
+		Ratio:   0.25,
+		Enabled: true,
+		Nested:  &testResponse{Message: "inner", Tags: []string{}},
+		Tags:    []string{"a", "b"},
+	}
+
+	for _, format := range Formats {
+		if format.Name == "text" {
+			continue
+		}
+
+		t.Run(format.Name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if err := format.Write(rec, want); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := rec.Header().Get("Content-Type"); got != format.ContentType() {
+				t.Errorf("Content-Type = %q, want %q", got, format.ContentType())
+			}
+
+			decodeWith, ok := FormatByMediaType(rec.Header().Get("Content-Type"))
+			if !ok || decodeWith.Name != format.Name {
+				t.Fatalf("FormatByMediaType() = %s, %v, want %s", decodeWith.Name, ok, format.Name)
+			}
+
+			var got testResponse
+			if err := decodeWith.Decode(rec.Body, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got.Message != want.Message || got.Count != want.Count || got.Large != want.Large ||
+				got.Ratio != want.Ratio || got.Enabled != want.Enabled || len(got.Tags) != len(want.Tags) ||
+				got.Nested == nil || got.Nested.Message != want.Nested.Message {
+				t.Errorf("decoded %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFormat_PlainText(t *testing.T) {
+	format, _ := FormatByName("text")
+	rec := httptest.NewRecorder()
+	if err := format.Write(rec, testResponse{Message: "pong from node-1"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := rec.Body.String(); got != "pong from node-1\n" {
+		t.Errorf("body = %q, want %q", got, "pong from node-1\n")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestUnmarshalCBOR_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated string", data: []byte{0x65, 'p', 'o'}},
+		{name: "string longer than input", data: []byte{0x7a, 0xff, 0xff, 0xff, 0xff}},
+		{name: "non string map key", data: []byte{0xa1, 0x01, 0x02}},
+		{name: "trailing bytes", data: []byte{0x01, 0x02}},
+		{name: "indefinite length", data: []byte{0x9f, 0xff}},
+		{name: "array longer than input", data: []byte{0x9a, 0x00, 0x0f, 0xff, 0xff, 0x01}},
+		{name: "map longer than input", data: []byte{0xba, 0x00, 0x0f, 0xff, 0xff, 0x61, 'a', 0x01}},
+		{name: "nested too deep", data: append(bytes.Repeat([]byte{0x81}, maxCBORDepth+1), 0x01)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if err := UnmarshalCBOR(tt.data, &v); err == nil {
+				t.Errorf("UnmarshalCBOR(%x) error = nil, want error", tt.data)
+			}
+		})
+	}
+}
+
+func FuzzUnmarshalCBOR(f *testing.F) {
+	seeds := [][]byte{
+		{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0x82, 0x02, 0x03},
+		{0xf9, 0x3c, 0x00},
+		{0xfa, 0x47, 0xc3, 0x50, 0x00},
+		{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+		{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		// Lengths far beyond the input
+		{0x7b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x61, 'a', 0x01},
+		// Nesting at and beyond the limit
+		append(bytes.Repeat([]byte{0x81}, maxCBORDepth), 0x01),
+		append(bytes.Repeat([]byte{0xa1, 0x61, 'a'}, maxCBORDepth+1), 0x01),
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		var v any
+		err := UnmarshalCBOR(data, &v)
+		runtime.ReadMemStats(&after)
+
+		// Lengths are checked against the input before anything is allocated
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20+uint64(len(data))*1024 {
+			t.Fatalf("UnmarshalCBOR(%x) allocated %d bytes", data, allocated)
+		}
+		if err != nil {
+			return
+		}
+
+		// Whatever decodes encodes again to the same value
+		encoded, err := MarshalCBOR(v)
+		if err != nil {
+			t.Fatalf("MarshalCBOR(%v) error = %v", v, err)
+		}
+		var decoded any
+		if err := UnmarshalCBOR(encoded, &decoded); err != nil {
+			t.Fatalf("UnmarshalCBOR(%x) error = %v", encoded, err)
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("round trip = %v, want %v", decoded, v)
+		}
+	})
+}
+
+func TestMarshalCBOR(t *testing.T) {
+	// RFC 8949 appendix A examples
+	data, err := MarshalCBOR(map[string]any{"a": 1, "b": []int{2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0x82, 0x02, 0x03}
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalCBOR() = %x, want %x", data, want)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Recover turns a panic in next into a 500 problem instead of a dropped connection
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// The server aborts the response on purpose, let it do so
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			slog.Error("handler panicked", "panic", rec, "path", r.URL.Path, "request_id", RequestID(r.Context()))
+			WriteProblem(w, r, http.StatusInternalServerError, "the request could not be processed")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblems(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	var handler http.Handler = Problems(mux)
+	handler = Recover(handler)
+	handler = WithRequestID(handler)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		requestID  string
+		wantStatus int
+		wantHeader map[string]string
+	}{
+		{name: "not found", method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound},
+		{
+			name:       "method not allowed",
+			method:     http.MethodDelete,
+			path:       "/ok",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantHeader: map[string]string{"Allow": "GET, HEAD"},
+		},
+		{name: "panic", method: http.MethodGet, path: "/panic", wantStatus: http.StatusInternalServerError},
+		{
+			name:       "request ID from the load balancer",
+			method:     http.MethodGet,
+			path:       "/missing",
+			requestID:  "lb-1234",
+			wantStatus: http.StatusNotFound,
+			wantHeader: map[string]string{RequestIDHeader: "lb-1234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+			}
+			for name, want := range tt.wantHeader {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if problem.Type != "about:blank" || problem.Status != tt.wantStatus || problem.Title != http.StatusText(tt.wantStatus) {
+				t.Errorf("problem = %+v, want about:blank with status %d", problem, tt.wantStatus)
+			}
+			if problem.Instance != tt.path {
+				t.Errorf("Instance = %q, want %q", problem.Instance, tt.path)
+			}
+			if problem.RequestID == "" || problem.RequestID != rec.Header().Get(RequestIDHeader) {
+				t.Errorf("RequestID = %q, want the %s header %q", problem.RequestID, RequestIDHeader, rec.Header().Get(RequestIDHeader))
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info describes the API in the OpenAPI document
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPI is an OpenAPI 3.1 document
+type OpenAPI struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Operation describes a method of a path
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a query parameter
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description"`
+	Schema      Schema `json:"schema"`
+}
+
+// Response describes a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string `json:"description"`
+	Schema      Schema `json:"schema"`
+}
+
+// MediaType holds the schema of a response body
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by the operations
+type Components struct {
+	Schemas map[string]Schema `json:"schemas"`
+}
+
+// Schema is a JSON Schema
+type Schema map[string]any
+
+// GenerateOpenAPI describes the routes, the schemas are generated from the
+// types of the route responses
+func GenerateOpenAPI(info Info, routes []Route) *OpenAPI {
+	generator := &schemaGenerator{schemas: make(map[string]Schema)}
+	problem := generator.schema(reflect.TypeFor[Problem]())
+
+	doc := &OpenAPI{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	for _, route := range routes {
+		operation := Operation{
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Parameters:  route.Parameters,
+			Responses: map[string]Response{
+				"default": {
+					Description: "Problem details (RFC 9457)",
+					Content:     map[string]MediaType{ProblemContentType: {Schema: problem}},
+				},
+			},
+		}
+
+		status := http.StatusOK
+		if route.Status != 0 {
+			status = route.Status
+		}
+		success := Response{
+			Description: "Success",
+			Headers: map[string]Header{
+				RequestIDHeader: {Description: "ID of the request", Schema: Schema{"type": "string"}},
+			},
+			Content: make(map[string]MediaType),
+		}
+
+		switch {
+		case status == http.StatusSwitchingProtocols:
+			// The connection speaks another protocol, there is no body
+			success.Description = "Switching Protocols"
+		case route.ContentType == "":
+			schema := generator.schema(reflect.TypeOf(route.Response))
+			for _, format := range Formats {
+				if format.Name == "text" {
+					success.Content[format.MediaType] = MediaType{Schema: Schema{"type": "string"}}
+					continue
+				}
+				success.Content[format.MediaType] = MediaType{Schema: schema}
+			}
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        "format",
+				In:          "query",
+				Description: "Response format, overrides the Accept header",
+				Schema:      Schema{"type": "string", "enum": FormatNames()},
+			})
+		default:
+			schema := Schema{"type": "string"}
+			if route.Response != nil {
+				schema = generator.schema(reflect.TypeOf(route.Response))
+			} else if route.ContentType == "application/json" {
+				schema = Schema{"type": "object"}
+			}
+			success.Content[route.ContentType] = MediaType{Schema: schema}
+		}
+		operation.Responses[strconv.Itoa(status)] = success
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]Operation)
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+
+	doc.Components.Schemas = generator.schemas
+	return doc
+}
+
+// serveOpenAPI describes the routes registered so far
+func (rt *Router) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(GenerateOpenAPI(rt.info, rt.routes)); err != nil {
+		slog.Error("failed to encode OpenAPI document", "error", err)
+	}
+}
+
+// schemaGenerator creates schemas from Go types following the rules of
+// encoding/json, named structs become components
+type schemaGenerator struct {
+	schemas map[string]Schema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeFor[time.Time]() {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			g.schemas[name] = Schema{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		return Schema{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	required := []string{}
+	g.addFields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the JSON fields of the struct, embedded structs without a
+// name are flattened like encoding/json does
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]Schema, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaName qualifies the type name with its package, both API versions
+// have a PingResponse
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + t.Name()
+}
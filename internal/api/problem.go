@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// ProblemContentType is the media type of problem details (RFC 9457)
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of every error response, see RFC 9457
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewProblem creates the problem for the status code, the type is
+// "about:blank" so the title is the standard status text
+func NewProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: RequestID(r.Context()),
+	}
+}
+
+// WriteProblem responds to the request with a problem for the status code
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	problem := NewProblem(r, status, detail)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		slog.Error("failed to encode problem", "error", err, "status", status)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Route is an endpoint of the API, it is served by the router and
+// documented in the OpenAPI document
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Parameters  []Parameter
+	Handler     http.Handler
+
+	// AnyMethod answers every other method with the handler too, for routes
+	// that did so before the router existed. Only Method is documented.
+	AnyMethod bool
+
+	// Response is a value of the success response type, its schema is
+	// generated from the type
+	Response any
+	// ContentType of the success response, the response is negotiated
+	// between the supported formats when empty
+	ContentType string
+	// Status of the success response, 200 when zero
+	Status int
+}
+
+// Router serves the registered routes and the OpenAPI document describing them
+type Router struct {
+	info    Info
+	mux     *http.ServeMux
+	handler http.Handler
+	routes  []Route
+}
+
+// NewRouter creates a router serving the OpenAPI document at
+// /api/openapi.json and a documentation page at /api/docs
+func NewRouter(info Info) *Router {
+	mux := http.NewServeMux()
+	router := &Router{
+		info:    info,
+		mux:     mux,
+		handler: Problems(mux),
+	}
+
+	router.Handle(Route{
+		Method:      http.MethodGet,
+		Path:        "/api/openapi.json",
+		OperationID: "getOpenAPI",
+		Summary:     "OpenAPI document of the API",
+		Handler:     http.HandlerFunc(router.serveOpenAPI),
+		ContentType: "application/json",
+	})
+	router.Handle(Route{
+		Method:      http.MethodGet,
+		Path:        "/api/docs",
+		OperationID: "getDocs",
+		Summary:     "Documentation page of the API",
+		Handler:     http.HandlerFunc(serveDocs),
+		ContentType: "text/html",
+	})
+
+	return router
+}
+
+// Handle registers the routes
+func (rt *Router) Handle(routes ...Route) {
+	for _, route := range routes {
+		pattern := route.Method + " " + route.Path
+		if route.AnyMethod {
+			pattern = route.Path
+		}
+		rt.mux.Handle(pattern, route.Handler)
+		rt.routes = append(rt.routes, route)
+	}
+}
+
+// Routes returns the registered routes
+func (rt *Router) Routes() []Route {
+	return rt.routes
+}
+
+// ServeHTTP serves the request with the matching route
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.handler.ServeHTTP(w, r)
+}
+
+// Problems serves the requests with mux, replacing its plain text 404 and
+// 405 responses with problems
+func Problems(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Without a pattern the mux answers with an error, find out which
+		rec := &errorRecorder{header: make(http.Header), status: http.StatusOK}
+		handler.ServeHTTP(rec, r)
+
+		switch rec.status {
+		case http.StatusMethodNotAllowed:
+			allow := rec.header.Get("Allow")
+			w.Header().Set("Allow", allow)
+			WriteProblem(w, r, rec.status, fmt.Sprintf("method %s is not allowed, use %s", r.Method, allow))
+		case http.StatusNotFound:
+			WriteProblem(w, r, rec.status, fmt.Sprintf("no resource at %s", r.URL.Path))
+		default:
+			WriteProblem(w, r, rec.status, "")
+		}
+	})
+}
+
+// errorRecorder keeps the status and headers of an error response and
+// discards its body
+type errorRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+}
+
+func (e *errorRecorder) Header() http.Header {
+	return e.header
+}
+
+func (e *errorRecorder) Write(p []byte) (int, error) {
+	e.wroteHeader = true
+	return len(p), nil
+}
+
+func (e *errorRecorder) WriteHeader(status int) {
+	if !e.wroteHeader {
+		e.status = status
+		e.wroteHeader = true
+	}
+}
@@ -1,39 +1,66 @@
 package v1
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
-	"os"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	v2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
 )
 
 // PingResponse represents the response
 // structure for the ping endpoint
 type PingResponse struct {
-	Message  string `json:"message"`
-	Hostname string `json:"hostname"`
+	Message  string `json:"message" xml:"message" yaml:"message"`
+	Hostname string `json:"hostname" xml:"hostname" yaml:"hostname"`
 }
 
-// pingHandler handles the /api/ping endpoint
-func PingHandler(w http.ResponseWriter, r *http.Request) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		slog.Error("failed to get hostname", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	response := PingResponse{
-		Message:  "pong",
-		Hostname: hostname,
-	}
+// PlainText returns the plain text form of the response
+func (p PingResponse) PlainText() string {
+	return p.Message + " from " + p.Hostname
+}
 
-	w.Header().Set("Content-Type", "application/json")
+// PingHandler returns the handler of the /api/ping endpoint, the response
+// is a frozen subset of the v2 node info
+func PingHandler(node *v2.Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := api.NegotiateCompatible(w, r)
+		if !ok {
+			return
+		}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		slog.Error("failed to encode response", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		info, err := node.Info(r)
+		if err != nil {
+			slog.Error("failed to describe node", "error", err)
+			api.WriteProblem(w, r, http.StatusInternalServerError, "the node could not be described")
+			return
+		}
+		response := PingResponse{
+			Message:  info.Message,
+			Hostname: info.Hostname,
+		}
+
+		if err := format.Write(w, response); err != nil {
+			slog.Error("failed to encode response", "error", err)
+			return
+		}
+
+		slog.Info("Ping request handled", "hostname", info.Hostname, "remote_addr", r.RemoteAddr)
 	}
+}
 
-	slog.Info("Ping request handled", "hostname", hostname, "remote_addr", r.RemoteAddr)
+// Routes returns the routes of API v1
+func Routes(node *v2.Node) []api.Route {
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/ping",
+			OperationID: "pingV1",
+			Summary:     "Hostname of the node serving the request",
+			Handler:     PingHandler(node),
+			Response:    PingResponse{},
+			// The endpoint answered every method before v1 was frozen
+			AnyMethod: true,
+		},
+	}
 }
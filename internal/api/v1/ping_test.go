@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	v2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+)
+
+// TestPingHandler guards the v1 wire format, it must never change
+func TestPingHandler(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := v2.NewNode("1.2.3", "0.0.0.0:8080", v2.TLSModeMutual)
+	rec := httptest.NewRecorder()
+	PingHandler(node)(rec, httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	want := `{"message":"pong","hostname":"` + hostname + `"}` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+// TestPingHandler_Negotiation makes sure clients that never asked for
+// another format keep getting JSON
+func TestPingHandler_Negotiation(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		accept     string
+		wantStatus int
+		wantType   string
+	}{
+		{name: "browser", target: "/api/ping", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", wantStatus: http.StatusOK, wantType: "application/json"},
+		{name: "unsupported type only", target: "/api/ping", accept: "text/html", wantStatus: http.StatusOK, wantType: "application/json"},
+		{name: "any type", target: "/api/ping", accept: "*/*", wantStatus: http.StatusOK, wantType: "application/json"},
+		{name: "wildcard subtype", target: "/api/ping", accept: "text/*", wantStatus: http.StatusOK, wantType: "application/json"},
+		{name: "exact type", target: "/api/ping", accept: "application/xml", wantStatus: http.StatusOK, wantType: "application/xml"},
+		{name: "exact type with parameters", target: "/api/ping", accept: "application/yaml; charset=utf-8", wantStatus: http.StatusOK, wantType: "application/yaml"},
+		{name: "query parameter", target: "/api/ping?format=text", accept: "text/html", wantStatus: http.StatusOK, wantType: "text/plain; charset=utf-8"},
+		{name: "unknown query parameter", target: "/api/ping?format=html", wantStatus: http.StatusNotAcceptable, wantType: "application/problem+json"},
+	}
+
+	node := v2.NewNode("1.2.3", "0.0.0.0:8080", v2.TLSModeDisabled)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			PingHandler(node)(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+// TestRoutes_AnyMethod guards that v1 keeps answering every method, as it
+// did before it was registered with the router
+func TestRoutes_AnyMethod(t *testing.T) {
+	node := v2.NewNode("1.2.3", "0.0.0.0:8080", v2.TLSModeDisabled)
+	router := api.NewRouter(api.Info{Title: "test", Version: "1.2.3"})
+	router.Handle(Routes(node)...)
+
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+	for _, method := range methods {
+		t.Run(method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(method, "/api/ping", strings.NewReader(`{"order":1}`)))
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+		})
+	}
+}
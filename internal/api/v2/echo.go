@@ -0,0 +1,263 @@
+package v2
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// redacted replaces the values of redacted headers
+const redacted = "[REDACTED]"
+
+// EchoMaxBodyBytes is the largest request body the echo endpoint reads
+const EchoMaxBodyBytes = 1 << 20
+
+// DefaultRedactHeaders are always redacted by the echo endpoint
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DefaultTrustedProxies are the networks the load balancer runs in when no
+// trusted proxies are configured
+var DefaultTrustedProxies = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// EchoOptions configures the echo endpoint
+type EchoOptions struct {
+	// RedactHeaders are redacted in addition to DefaultRedactHeaders
+	RedactHeaders []string
+	// TrustedProxies may add to X-Forwarded-For, DefaultTrustedProxies when nil
+	TrustedProxies []netip.Prefix
+}
+
+// EchoResponse describes the request as the node received it
+type EchoResponse struct {
+	Hostname     string              `json:"hostname"`
+	Method       string              `json:"method"`
+	URL          string              `json:"url"`
+	Host         string              `json:"host"`
+	Proto        string              `json:"proto"`
+	Headers      map[string][]string `json:"headers"`
+	RemoteAddr   string              `json:"remote_addr"`
+	ForwardedFor []string            `json:"forwarded_for,omitempty"`
+	ClientIP     string              `json:"client_ip"`
+	TLS          *EchoTLS            `json:"tls,omitempty"`
+	// BodyBytes and BodySHA256 describe the request body, which is not echoed
+	BodyBytes  int64  `json:"body_bytes"`
+	BodySHA256 string `json:"body_sha256,omitempty"`
+}
+
+// EchoTLS describes the TLS connection of the request
+type EchoTLS struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	ALPN        string `json:"alpn"`
+	ServerName  string `json:"server_name"`
+	Resumed     bool   `json:"resumed"`
+	// PeerChain is the verified chain of the client certificate, leaf first
+	PeerChain []CertificateSummary `json:"peer_chain,omitempty"`
+}
+
+// CertificateSummary describes a certificate of the peer chain
+type CertificateSummary struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	IsCA         bool      `json:"is_ca"`
+	SHA256       string    `json:"sha256"`
+}
+
+// EchoHandler returns the handler of the /api/v2/echo endpoint
+func EchoHandler(node *Node, opts EchoOptions) http.HandlerFunc {
+	redact := make(map[string]bool)
+	for _, name := range slices.Concat(DefaultRedactHeaders, opts.RedactHeaders) {
+		redact[http.CanonicalHeaderKey(name)] = true
+	}
+	trusted := opts.TrustedProxies
+	if trusted == nil {
+		trusted = DefaultTrustedProxies
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := node.Info(r)
+		if err != nil {
+			slog.Error("failed to describe node", "error", err)
+			api.WriteProblem(w, r, http.StatusInternalServerError, "the node could not be described")
+			return
+		}
+
+		response := EchoResponse{
+			Hostname:     info.Hostname,
+			Method:       r.Method,
+			URL:          r.URL.RequestURI(),
+			Host:         r.Host,
+			Proto:        r.Proto,
+			Headers:      make(map[string][]string, len(r.Header)),
+			RemoteAddr:   r.RemoteAddr,
+			ForwardedFor: forwardedFor(r.Header),
+			TLS:          echoTLS(r.TLS),
+		}
+		response.ClientIP = clientIP(r.RemoteAddr, response.ForwardedFor, trusted)
+
+		if r.ContentLength > EchoMaxBodyBytes {
+			api.WriteProblem(w, r, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request body of %d bytes exceeds the limit of %d bytes", r.ContentLength, EchoMaxBodyBytes))
+			return
+		}
+		hash := sha256.New()
+		response.BodyBytes, err = io.Copy(hash, http.MaxBytesReader(w, r.Body, EchoMaxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				api.WriteProblem(w, r, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body exceeds the limit of %d bytes", EchoMaxBodyBytes))
+				return
+			}
+			api.WriteProblem(w, r, http.StatusBadRequest, "the request body could not be read")
+			return
+		}
+		if response.BodyBytes > 0 {
+			response.BodySHA256 = hex.EncodeToString(hash.Sum(nil))
+		}
+
+		for name, values := range r.Header {
+			if redact[name] {
+				values = []string{redacted}
+			}
+			response.Headers[name] = values
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode response", "error", err)
+			return
+		}
+
+		slog.Info("Echo request handled", "hostname", info.Hostname, "remote_addr", r.RemoteAddr, "client_ip", response.ClientIP)
+	}
+}
+
+// EchoRoutes returns the routes of the echo endpoint, they are only
+// registered when enabled because they expose request internals
+func EchoRoutes(node *Node, opts EchoOptions) []api.Route {
+	handler := EchoHandler(node, opts)
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/v2/echo",
+			OperationID: "echoV2",
+			Summary:     "The request as the node received it, including the proxy and TLS details",
+			Handler:     handler,
+			Response:    EchoResponse{},
+			ContentType: "application/json",
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/v2/echo",
+			OperationID: "echoV2Post",
+			Summary:     "The request as the node received it, with the size and SHA-256 of a body up to 1 MiB",
+			Handler:     handler,
+			Response:    EchoResponse{},
+			ContentType: "application/json",
+		},
+	}
+}
+
+// forwardedFor returns the addresses of all X-Forwarded-For headers
+func forwardedFor(header http.Header) []string {
+	var addresses []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// clientIP walks the proxy chain from the node backwards and returns the
+// first address that is not a trusted proxy, addresses left of it could
+// have been made up by the client
+func clientIP(remoteAddr string, forwarded []string, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	chain := append(append([]string{}, forwarded...), host)
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(chain[i])
+		if err != nil || !isTrusted(addr.Unmap(), trusted) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// echoTLS describes the TLS connection, nil for plain connections
+func echoTLS(state *tls.ConnectionState) *EchoTLS {
+	if state == nil {
+		return nil
+	}
+
+	echo := &EchoTLS{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+		Resumed:     state.DidResume,
+	}
+	if len(state.VerifiedChains) > 0 {
+		for _, cert := range state.VerifiedChains[0] {
+			echo.PeerChain = append(echo.PeerChain, summarize(cert))
+		}
+	}
+	return echo
+}
+
+func summarize(cert *x509.Certificate) CertificateSummary {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return CertificateSummary{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		DNSNames:     cert.DNSNames,
+		IsCA:         cert.IsCA,
+		SHA256:       hex.EncodeToString(fingerprint[:]),
+	}
+}
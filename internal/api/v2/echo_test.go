@@ -0,0 +1,176 @@
+package v2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:51000", want: "203.0.113.7"},
+		{name: "through the load balancer", remoteAddr: "10.0.0.2:40000", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{
+			name:       "spoofed entries left of the client are ignored",
+			remoteAddr: "10.0.0.2:40000",
+			forwarded:  []string{"1.2.3.4", "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted direct client cannot forward",
+			remoteAddr: "198.51.100.1:40000",
+			forwarded:  []string{"203.0.113.7"},
+			want:       "198.51.100.1",
+		},
+		{name: "only trusted proxies", remoteAddr: "10.0.0.2:40000", forwarded: []string{"10.0.0.3"}, want: "10.0.0.3"},
+		{name: "invalid forwarded address", remoteAddr: "10.0.0.2:40000", forwarded: []string{"unknown"}, want: "unknown"},
+		{name: "ipv6 load balancer", remoteAddr: "[fd00::2]:40000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(tt.remoteAddr, tt.forwarded, DefaultTrustedProxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoHandler(t *testing.T) {
+	leaf := &x509.Certificate{
+		Raw:          []byte("leaf"),
+		Subject:      pkix.Name{CommonName: "caddy"},
+		Issuer:       pkix.Name{CommonName: "alcatraz-ca"},
+		SerialNumber: big.NewInt(2),
+		DNSNames:     []string{"caddy"},
+	}
+	root := &x509.Certificate{
+		Raw:          []byte("root"),
+		Subject:      pkix.Name{CommonName: "alcatraz-ca"},
+		Issuer:       pkix.Name{CommonName: "alcatraz-ca"},
+		SerialNumber: big.NewInt(1),
+		IsCA:         true,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/echo?debug=1", nil)
+	req.RemoteAddr = "192.0.2.10:40000"
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Internal-Token", "secret")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("User-Agent", "test")
+	req.TLS = &tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		NegotiatedProtocol: "h2",
+		ServerName:         "node-1",
+		VerifiedChains:     [][]*x509.Certificate{{leaf, root}},
+	}
+
+	opts := EchoOptions{
+		RedactHeaders:  []string{"x-internal-token"},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	}
+	rec := httptest.NewRecorder()
+	EchoHandler(NewNode("1.2.3", "0.0.0.0:8080", TLSModeMutual), opts)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp EchoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Method != http.MethodGet || resp.URL != "/api/v2/echo?debug=1" || resp.Proto != "HTTP/1.1" {
+		t.Errorf("method, url, proto = %q, %q, %q", resp.Method, resp.URL, resp.Proto)
+	}
+	for _, name := range []string{"Authorization", "X-Internal-Token"} {
+		if got := resp.Headers[name]; len(got) != 1 || got[0] != redacted {
+			t.Errorf("Headers[%s] = %v, want redacted", name, got)
+		}
+	}
+	if got := resp.Headers["User-Agent"]; len(got) != 1 || got[0] != "test" {
+		t.Errorf("Headers[User-Agent] = %v, want test", got)
+	}
+	if resp.ClientIP != "203.0.113.7" {
+		t.Errorf("ClientIP = %q, want 203.0.113.7", resp.ClientIP)
+	}
+
+	if resp.TLS == nil {
+		t.Fatal("TLS = nil, want the connection details")
+	}
+	if resp.TLS.Version != "TLS 1.3" || resp.TLS.CipherSuite != "TLS_AES_128_GCM_SHA256" || resp.TLS.ALPN != "h2" || resp.TLS.ServerName != "node-1" {
+		t.Errorf("TLS = %+v", resp.TLS)
+	}
+	if len(resp.TLS.PeerChain) != 2 || resp.TLS.PeerChain[0].Subject != "CN=caddy" || !resp.TLS.PeerChain[1].IsCA {
+		t.Errorf("PeerChain = %+v, want caddy followed by the CA", resp.TLS.PeerChain)
+	}
+}
+
+func TestEchoHandler_Body(t *testing.T) {
+	large := strings.Repeat("a", EchoMaxBodyBytes+1)
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		wantStatus    int
+		wantBytes     int64
+		wantSHA256    string
+	}{
+		{name: "no body", wantStatus: http.StatusOK},
+		{
+			name:       "small body",
+			body:       strings.NewReader("hello"),
+			wantStatus: http.StatusOK,
+			wantBytes:  5,
+			wantSHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+		{name: "body over the limit", body: strings.NewReader(large), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "body over the limit without a length", body: strings.NewReader(large), contentLength: -1, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/echo", tt.body)
+			if tt.contentLength != 0 {
+				req.ContentLength = tt.contentLength
+			}
+			rec := httptest.NewRecorder()
+			EchoHandler(NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled), EchoOptions{})(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got := rec.Header().Get("Content-Type"); got != api.ProblemContentType {
+					t.Errorf("Content-Type = %q, want %q", got, api.ProblemContentType)
+				}
+				return
+			}
+
+			var resp EchoResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.BodyBytes != tt.wantBytes || resp.BodySHA256 != tt.wantSHA256 {
+				t.Errorf("BodyBytes, BodySHA256 = %d, %q, want %d, %q", resp.BodyBytes, resp.BodySHA256, tt.wantBytes, tt.wantSHA256)
+			}
+		})
+	}
+}
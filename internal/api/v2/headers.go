@@ -0,0 +1,77 @@
+package v2
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseHeaders configures the headers identifying the node on every
+// response, so clients that send HEAD requests or skip the body can tell
+// which node served them. Empty header names leave a header out.
+type ResponseHeaders struct {
+	// ServedBy carries the node name
+	ServedBy string
+	// NodeVersion carries the application version, left out when unknown
+	NodeVersion string
+	// ServerTiming adds a Server-Timing "app" metric with the time the
+	// handler took until it wrote the response headers
+	ServerTiming bool
+}
+
+// WithHeaders sets the configured headers on every response served by next
+func (n *Node) WithHeaders(headers ResponseHeaders, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if headers.ServedBy != "" {
+			w.Header().Set(headers.ServedBy, n.metadata().Name)
+		}
+		if headers.NodeVersion != "" && n.Version != "" {
+			w.Header().Set(headers.NodeVersion, n.Version)
+		}
+		if !headers.ServerTiming {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timing := &timingWriter{ResponseWriter: w, start: time.Now()}
+		next.ServeHTTP(timing, r)
+		// The server writes the headers of empty responses after the handler
+		timing.setHeader()
+	})
+}
+
+// timingWriter adds the Server-Timing header once the handler writes the
+// response headers
+type timingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+// setHeader sets the Server-Timing header unless it was set before
+func (t *timingWriter) setHeader() {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+	duration := float64(time.Since(t.start).Microseconds()) / 1000
+	t.Header().Set("Server-Timing", "app;dur="+strconv.FormatFloat(duration, 'f', 3, 64))
+}
+
+func (t *timingWriter) WriteHeader(status int) {
+	// Informational responses are followed by the final one
+	if status >= http.StatusOK {
+		t.setHeader()
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *timingWriter) Write(p []byte) (int, error) {
+	t.setHeader()
+	return t.ResponseWriter.Write(p)
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (t *timingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,78 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNode_WithHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	})
+	mux.HandleFunc("GET /empty", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /hints", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	all := ResponseHeaders{ServedBy: "X-Served-By", NodeVersion: "X-Node-Version", ServerTiming: true}
+
+	tests := []struct {
+		name        string
+		headers     ResponseHeaders
+		version     string
+		method      string
+		path        string
+		wantStatus  int
+		wantServed  string
+		wantVersion string
+		wantTiming  bool
+	}{
+		{name: "body", headers: all, version: "1.2.3", path: "/ping", wantStatus: http.StatusOK, wantServed: "node-1", wantVersion: "1.2.3", wantTiming: true},
+		{name: "head request", headers: all, version: "1.2.3", method: http.MethodHead, path: "/ping", wantStatus: http.StatusOK, wantServed: "node-1", wantVersion: "1.2.3", wantTiming: true},
+		{name: "error", headers: all, version: "1.2.3", path: "/missing", wantStatus: http.StatusNotFound, wantServed: "node-1", wantVersion: "1.2.3", wantTiming: true},
+		{name: "empty response", headers: all, version: "1.2.3", path: "/empty", wantStatus: http.StatusOK, wantServed: "node-1", wantVersion: "1.2.3", wantTiming: true},
+		{name: "informational response first", headers: all, version: "1.2.3", path: "/hints", wantStatus: http.StatusNoContent, wantServed: "node-1", wantVersion: "1.2.3", wantTiming: true},
+		{name: "unknown version", headers: all, path: "/ping", wantStatus: http.StatusOK, wantServed: "node-1", wantTiming: true},
+		{name: "headers left out", version: "1.2.3", path: "/ping", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NewNode(tt.version, "0.0.0.0:8080", TLSModeDisabled)
+			node.Metadata = NodeMetadata{Name: "node-1"}
+			server := httptest.NewServer(node.WithHeaders(tt.headers, mux))
+			defer server.Close()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("X-Served-By"); got != tt.wantServed {
+				t.Errorf("X-Served-By = %q, want %q", got, tt.wantServed)
+			}
+			if got := resp.Header.Get("X-Node-Version"); got != tt.wantVersion {
+				t.Errorf("X-Node-Version = %q, want %q", got, tt.wantVersion)
+			}
+			if got := resp.Header.Get("Server-Timing"); strings.HasPrefix(got, "app;dur=") != tt.wantTiming {
+				t.Errorf("Server-Timing = %q, want an app duration: %v", got, tt.wantTiming)
+			}
+		})
+	}
+}
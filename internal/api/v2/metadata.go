@@ -0,0 +1,153 @@
+package v2
+
+import (
+	"encoding/xml"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// NodeHeader identifies the node on every response, e.g.
+// "node-1; region=eu-central-1; team=edge; zone=eu-central-1a"
+const NodeHeader = "X-Alcatraz-Node"
+
+// NodeMetadata identifies the node independently of its hostname, which is
+// a random container ID in Docker
+type NodeMetadata struct {
+	// Name is stable across restarts, the hostname when not configured
+	Name   string `json:"name" xml:"name" yaml:"name"`
+	Zone   string `json:"zone,omitempty" xml:"zone,omitempty" yaml:"zone,omitempty"`
+	Region string `json:"region,omitempty" xml:"region,omitempty" yaml:"region,omitempty"`
+	Labels Labels `json:"labels,omitempty" xml:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// Header returns the value of NodeHeader, the zone and region are written
+// as labels
+func (m NodeMetadata) Header() string {
+	labels := m.AllLabels()
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString(escapeHeaderValue(m.Name))
+	for _, key := range keys {
+		b.WriteString("; " + escapeHeaderValue(key) + "=" + escapeHeaderValue(labels[key]))
+	}
+	return b.String()
+}
+
+// headerEscaper percent-encodes the separators of NodeHeader, the config
+// rejects them but the hostname a node falls back to is not checked
+var headerEscaper = strings.NewReplacer("%", "%25", ";", "%3B", ",", "%2C", "=", "%3D")
+
+// headerUnescaper reverses headerEscaper
+var headerUnescaper = strings.NewReplacer("%25", "%", "%3B", ";", "%2C", ",", "%3D", "=")
+
+// escapeHeaderValue returns value with the separators of NodeHeader escaped
+func escapeHeaderValue(value string) string {
+	return headerEscaper.Replace(value)
+}
+
+// unescapeHeaderValue returns value with the escapes of escapeHeaderValue
+// decoded, other percent signs are kept
+func unescapeHeaderValue(value string) string {
+	return headerUnescaper.Replace(value)
+}
+
+// AllLabels returns the labels including the zone and region
+func (m NodeMetadata) AllLabels() map[string]string {
+	labels := make(map[string]string, len(m.Labels)+2)
+	for key, value := range m.Labels {
+		labels[key] = value
+	}
+	if m.Zone != "" {
+		labels["zone"] = m.Zone
+	}
+	if m.Region != "" {
+		labels["region"] = m.Region
+	}
+	return labels
+}
+
+// ParseNodeHeader reads the node name and labels from the value of NodeHeader
+func ParseNodeHeader(value string) (string, map[string]string) {
+	parts := strings.Split(value, ";")
+	name := unescapeHeaderValue(strings.TrimSpace(parts[0]))
+
+	labels := make(map[string]string)
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && key != "" {
+			labels[unescapeHeaderValue(key)] = unescapeHeaderValue(value)
+		}
+	}
+	return name, labels
+}
+
+// Identify sets NodeHeader on every response served by next
+func (n *Node) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(NodeHeader, n.metadata().Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metadata returns the node metadata, an unnamed node is named after its
+// hostname
+func (n *Node) metadata() NodeMetadata {
+	metadata := n.Metadata
+	if metadata.Name == "" {
+		metadata.Name, _ = os.Hostname()
+	}
+	return metadata
+}
+
+// Labels are arbitrary key value pairs describing the node
+type Labels map[string]string
+
+// MarshalXML writes the labels as <label name="key">value</label> elements
+// sorted by key, encoding/xml cannot encode maps
+func (l Labels) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		label := xml.StartElement{
+			Name: xml.Name{Local: "label"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: key}},
+		}
+		if err := e.EncodeElement(l[key], label); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML reads the labels written by MarshalXML
+func (l *Labels) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var labels struct {
+		Label []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"label"`
+	}
+	if err := d.DecodeElement(&labels, &start); err != nil {
+		return err
+	}
+
+	*l = make(Labels, len(labels.Label))
+	for _, label := range labels.Label {
+		(*l)[label.Name] = label.Value
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package v2
+
+import (
+	"encoding/xml"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNodeMetadata_Header(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   NodeMetadata
+		wantHeader string
+	}{
+		{
+			name:       "name only",
+			metadata:   NodeMetadata{Name: "node-1"},
+			wantHeader: "node-1",
+		},
+		{
+			name: "zone, region and labels sorted by key",
+			metadata: NodeMetadata{
+				Name:   "node-1",
+				Zone:   "eu-central-1a",
+				Region: "eu-central-1",
+				Labels: Labels{"team": "edge", "example.com/tier": "gold"},
+			},
+			wantHeader: "node-1; example.com/tier=gold; region=eu-central-1; team=edge; zone=eu-central-1a",
+		},
+		{
+			name: "separators escaped",
+			metadata: NodeMetadata{
+				Name:   "node;1",
+				Labels: Labels{"team": "edge; zone=b", "owner": "a,b", "quota": "100%"},
+			},
+			wantHeader: "node%3B1; owner=a%2Cb; quota=100%25; team=edge%3B zone%3Db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.metadata.Header()
+			if header != tt.wantHeader {
+				t.Errorf("Header() = %q, want %q", header, tt.wantHeader)
+			}
+
+			name, labels := ParseNodeHeader(header)
+			if name != tt.metadata.Name || !maps.Equal(labels, tt.metadata.AllLabels()) {
+				t.Errorf("ParseNodeHeader() = %q, %v, want %q, %v", name, labels, tt.metadata.Name, tt.metadata.AllLabels())
+			}
+		})
+	}
+}
+
+func TestNode_Identify(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		metadata   NodeMetadata
+		wantHeader string
+	}{
+		{name: "unnamed node", wantHeader: hostname},
+		{name: "named node", metadata: NodeMetadata{Name: "node-1", Zone: "a"}, wantHeader: "node-1; zone=a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+			node.Metadata = tt.metadata
+
+			rec := httptest.NewRecorder()
+			node.Identify(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+			if got := rec.Header().Get(NodeHeader); got != tt.wantHeader {
+				t.Errorf("%s = %q, want %q", NodeHeader, got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestLabels_XML(t *testing.T) {
+	metadata := NodeMetadata{Name: "node-1", Labels: Labels{"team": "edge", "example.com/tier": "gold"}}
+
+	data, err := xml.Marshal(metadata)
+	if err != nil {
+		t.Fatalf("xml.Marshal() error = %v", err)
+	}
+	want := `<NodeMetadata><name>node-1</name><labels><label name="example.com/tier">gold</label><label name="team">edge</label></labels></NodeMetadata>`
+	if string(data) != want {
+		t.Errorf("xml.Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded NodeMetadata
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+	if !maps.Equal(decoded.Labels, metadata.Labels) {
+		t.Errorf("xml.Unmarshal() labels = %v, want %v", decoded.Labels, metadata.Labels)
+	}
+}
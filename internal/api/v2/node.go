@@ -0,0 +1,153 @@
+package v2
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// TLSMode describes how the node accepts connections
+type TLSMode string
+
+const (
+	TLSModeDisabled TLSMode = "disabled"
+	TLSModeTLS      TLSMode = "tls"
+	TLSModeMutual   TLSMode = "mtls"
+)
+
+// Node holds the details of the application node, every API version
+// describes the node through it
+type Node struct {
+	ID            string
+	Version       string
+	StartTime     time.Time
+	ListenAddress string
+	TLSMode       TLSMode
+	// Metadata is the configured identity of the node, the name defaults
+	// to the hostname
+	Metadata NodeMetadata
+
+	inFlight  atomic.Int64
+	drainOnce sync.Once
+	draining  chan struct{}
+}
+
+// NewNode creates the node description, the node ID is random and changes
+// with every start so restarts of the same host can be told apart
+func NewNode(version, listenAddress string, tlsMode TLSMode) *Node {
+	return &Node{
+		ID:            rand.Text(),
+		Version:       version,
+		StartTime:     time.Now(),
+		ListenAddress: listenAddress,
+		TLSMode:       tlsMode,
+		draining:      make(chan struct{}),
+	}
+}
+
+// Track counts the requests being served by next
+func (n *Node) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.inFlight.Add(1)
+		defer n.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectDraining answers requests that arrive after the node started
+// draining with a 503 problem, the client retries them on another node
+func (n *Node) RejectDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.IsDraining() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			api.WriteProblem(w, r, http.StatusServiceUnavailable, "the node is shutting down")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests being served
+func (n *Node) InFlight() int64 {
+	return n.inFlight.Load()
+}
+
+// Drain marks the node as shutting down, long-lived responses such as
+// streams end so the server can stop
+func (n *Node) Drain() {
+	n.drainOnce.Do(func() { close(n.draining) })
+}
+
+// Draining returns a channel that is closed once the node drains
+func (n *Node) Draining() <-chan struct{} {
+	return n.draining
+}
+
+// IsDraining reports whether the node is shutting down
+func (n *Node) IsDraining() bool {
+	select {
+	case <-n.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// Info describes the node as seen by the request r
+func (n *Node) Info(r *http.Request) (PingResponse, error) {
+	return n.Describe(r.TLS)
+}
+
+// Describe describes the node as seen by a connection with the TLS state,
+// nil for plain connections
+func (n *Node) Describe(state *tls.ConnectionState) (PingResponse, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return PingResponse{}, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	return PingResponse{
+		Message:       "pong",
+		Hostname:      hostname,
+		NodeID:        n.ID,
+		Version:       n.Version,
+		StartTime:     n.StartTime,
+		UptimeSeconds: time.Since(n.StartTime).Seconds(),
+		Runtime: RuntimeInfo{
+			GoVersion:    runtime.Version(),
+			OS:           runtime.GOOS,
+			Arch:         runtime.GOARCH,
+			NumCPU:       runtime.NumCPU(),
+			NumGoroutine: runtime.NumGoroutine(),
+		},
+		ListenAddress: n.ListenAddress,
+		TLSMode:       n.TLSMode,
+		Client:        clientIdentity(state),
+		Node:          n.metadata(),
+	}, nil
+}
+
+// clientIdentity returns the verified client certificate of the connection,
+// nil when the client did not present one
+func clientIdentity(state *tls.ConnectionState) *ClientIdentity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	return &ClientIdentity{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		NotAfter:     cert.NotAfter,
+	}
+}
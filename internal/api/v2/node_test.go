@@ -0,0 +1,46 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+func TestNode_RejectDraining(t *testing.T) {
+	tests := []struct {
+		name       string
+		drain      bool
+		wantStatus int
+		wantType   string
+	}{
+		{name: "serving", wantStatus: http.StatusNoContent},
+		{name: "draining", drain: true, wantStatus: http.StatusServiceUnavailable, wantType: api.ProblemContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+			if tt.drain {
+				node.Drain()
+			}
+			handler := node.RejectDraining(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/ping", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if tt.drain && rec.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After is not set")
+			}
+		})
+	}
+}
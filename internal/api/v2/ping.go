@@ -0,0 +1,85 @@
+package v2
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// PingResponse represents the response
+// structure for the v2 ping endpoint
+type PingResponse struct {
+	Message       string          `json:"message" xml:"message" yaml:"message"`
+	Hostname      string          `json:"hostname" xml:"hostname" yaml:"hostname"`
+	NodeID        string          `json:"node_id" xml:"node_id" yaml:"node_id"`
+	Version       string          `json:"version" xml:"version" yaml:"version"`
+	StartTime     time.Time       `json:"start_time" xml:"start_time" yaml:"start_time"`
+	UptimeSeconds float64         `json:"uptime_seconds" xml:"uptime_seconds" yaml:"uptime_seconds"`
+	Runtime       RuntimeInfo     `json:"runtime" xml:"runtime" yaml:"runtime"`
+	ListenAddress string          `json:"listen_address" xml:"listen_address" yaml:"listen_address"`
+	TLSMode       TLSMode         `json:"tls_mode" xml:"tls_mode" yaml:"tls_mode"`
+	Client        *ClientIdentity `json:"client,omitempty" xml:"client,omitempty" yaml:"client,omitempty"`
+	Node          NodeMetadata    `json:"node" xml:"node" yaml:"node"`
+}
+
+// PlainText returns the plain text form of the response
+func (p PingResponse) PlainText() string {
+	return p.Message + " from " + p.Hostname
+}
+
+// RuntimeInfo describes the Go runtime of the node
+type RuntimeInfo struct {
+	GoVersion    string `json:"go_version" xml:"go_version" yaml:"go_version"`
+	OS           string `json:"os" xml:"os" yaml:"os"`
+	Arch         string `json:"arch" xml:"arch" yaml:"arch"`
+	NumCPU       int    `json:"num_cpu" xml:"num_cpu" yaml:"num_cpu"`
+	NumGoroutine int    `json:"num_goroutine" xml:"num_goroutine" yaml:"num_goroutine"`
+}
+
+// ClientIdentity describes the verified client certificate
+type ClientIdentity struct {
+	Subject      string    `json:"subject" xml:"subject" yaml:"subject"`
+	Issuer       string    `json:"issuer" xml:"issuer" yaml:"issuer"`
+	SerialNumber string    `json:"serial_number" xml:"serial_number" yaml:"serial_number"`
+	NotAfter     time.Time `json:"not_after" xml:"not_after" yaml:"not_after"`
+}
+
+// PingHandler returns the handler of the /api/v2/ping endpoint
+func PingHandler(node *Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := api.Negotiate(w, r)
+		if !ok {
+			return
+		}
+
+		response, err := node.Info(r)
+		if err != nil {
+			slog.Error("failed to describe node", "error", err)
+			api.WriteProblem(w, r, http.StatusInternalServerError, "the node could not be described")
+			return
+		}
+
+		if err := format.Write(w, response); err != nil {
+			slog.Error("failed to encode response", "error", err)
+			return
+		}
+
+		slog.Info("Ping request handled", "api", "v2", "hostname", response.Hostname, "remote_addr", r.RemoteAddr)
+	}
+}
+
+// Routes returns the routes of API v2
+func Routes(node *Node) []api.Route {
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/v2/ping",
+			OperationID: "pingV2",
+			Summary:     "Details of the node serving the request",
+			Handler:     PingHandler(node),
+			Response:    PingResponse{},
+		},
+	}
+}
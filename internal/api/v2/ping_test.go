@@ -0,0 +1,96 @@
+package v2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPingHandler(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "caddy"},
+		Issuer:       pkix.Name{CommonName: "alcatraz-ca"},
+		SerialNumber: big.NewInt(42),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name       string
+		tls        *tls.ConnectionState
+		wantClient *ClientIdentity
+	}{
+		{
+			name: "plain connection",
+		},
+		{
+			name: "connection without client certificate",
+			tls:  &tls.ConnectionState{},
+		},
+		{
+			name: "verified client certificate",
+			tls:  &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{clientCert}}},
+			wantClient: &ClientIdentity{
+				Subject:      "CN=caddy",
+				Issuer:       "CN=alcatraz-ca",
+				SerialNumber: "42",
+				NotAfter:     clientCert.NotAfter,
+			},
+		},
+	}
+
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeMutual)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v2/ping", nil)
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+			PingHandler(node)(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var resp PingResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if resp.Message != "pong" || resp.Hostname != hostname {
+				t.Errorf("message, hostname = %q, %q, want pong, %q", resp.Message, resp.Hostname, hostname)
+			}
+			if resp.NodeID != node.ID || resp.NodeID == "" {
+				t.Errorf("NodeID = %q, want %q", resp.NodeID, node.ID)
+			}
+			if resp.Version != "1.2.3" || resp.ListenAddress != "0.0.0.0:8080" || resp.TLSMode != TLSModeMutual {
+				t.Errorf("version, listen address, TLS mode = %q, %q, %q", resp.Version, resp.ListenAddress, resp.TLSMode)
+			}
+			if !resp.StartTime.Equal(node.StartTime) || resp.UptimeSeconds < 0 {
+				t.Errorf("start time, uptime = %v, %v", resp.StartTime, resp.UptimeSeconds)
+			}
+			if resp.Runtime.GoVersion != runtime.Version() || resp.Runtime.NumCPU < 1 {
+				t.Errorf("Runtime = %+v", resp.Runtime)
+			}
+
+			switch {
+			case tt.wantClient == nil && resp.Client != nil:
+				t.Errorf("Client = %+v, want none", resp.Client)
+			case tt.wantClient != nil && (resp.Client == nil || *resp.Client != *tt.wantClient):
+				t.Errorf("Client = %+v, want %+v", resp.Client, tt.wantClient)
+			}
+		})
+	}
+}
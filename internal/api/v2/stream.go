@@ -0,0 +1,141 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+const (
+	// DefaultStreamInterval is the time between heartbeats
+	DefaultStreamInterval = time.Second
+	// MinStreamInterval and MaxStreamInterval bound the interval parameter
+	MinStreamInterval = 100 * time.Millisecond
+	MaxStreamInterval = time.Minute
+)
+
+// Heartbeat is an event of the stream describing the node state
+type Heartbeat struct {
+	Hostname string    `json:"hostname"`
+	NodeID   string    `json:"node_id"`
+	Sequence uint64    `json:"sequence"`
+	Time     time.Time `json:"time"`
+	// InFlight counts the requests being served, including the stream
+	InFlight int64 `json:"in_flight"`
+	// Draining is set on the last heartbeat when the node shuts down
+	Draining bool `json:"draining"`
+}
+
+// StreamHandler returns the handler of the /api/v2/stream endpoint, it
+// sends a heartbeat as a server-sent event right away and then every
+// interval until the client disconnects or the node drains
+func StreamHandler(node *Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		interval := DefaultStreamInterval
+		if value := r.URL.Query().Get("interval"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < MinStreamInterval || d > MaxStreamInterval {
+				api.WriteProblem(w, r, http.StatusBadRequest,
+					fmt.Sprintf("invalid interval: %q (must be a duration between %s and %s)", value, MinStreamInterval, MaxStreamInterval))
+				return
+			}
+			interval = d
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			slog.Error("failed to get hostname", "error", err)
+			api.WriteProblem(w, r, http.StatusInternalServerError, "the node could not be described")
+			return
+		}
+
+		controller := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		// Keep proxies such as nginx from buffering the events
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		// Clients reconnect quickly, to another node when this one drains
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds()); err != nil {
+			return
+		}
+
+		slog.Info("Stream started", "hostname", hostname, "remote_addr", r.RemoteAddr, "interval", interval.String())
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var sequence uint64
+		for {
+			sequence++
+			heartbeat := Heartbeat{
+				Hostname: hostname,
+				NodeID:   node.ID,
+				Sequence: sequence,
+				Time:     time.Now(),
+				InFlight: node.InFlight(),
+				Draining: node.IsDraining(),
+			}
+			if err := writeEvent(w, controller, sequence, heartbeat); err != nil {
+				slog.Info("Stream ended", "hostname", hostname, "remote_addr", r.RemoteAddr, "heartbeats", sequence-1, "error", err)
+				return
+			}
+			if heartbeat.Draining {
+				slog.Info("Stream ended", "hostname", hostname, "remote_addr", r.RemoteAddr, "heartbeats", sequence, "reason", "draining")
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-node.Draining():
+			case <-r.Context().Done():
+				slog.Info("Stream ended", "hostname", hostname, "remote_addr", r.RemoteAddr, "heartbeats", sequence, "reason", "client disconnected")
+				return
+			}
+		}
+	}
+}
+
+// StreamRoutes returns the route of the heartbeat stream
+func StreamRoutes(node *Node) []api.Route {
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/v2/stream",
+			OperationID: "streamV2",
+			Summary:     "Server-sent heartbeat events of the node serving the connection",
+			Parameters: []api.Parameter{
+				{
+					Name:        "interval",
+					In:          "query",
+					Description: fmt.Sprintf("Time between heartbeats, between %s and %s", MinStreamInterval, MaxStreamInterval),
+					Schema:      api.Schema{"type": "string", "default": DefaultStreamInterval.String()},
+				},
+			},
+			Handler:     StreamHandler(node),
+			Response:    Heartbeat{},
+			ContentType: "text/event-stream",
+		},
+	}
+}
+
+// writeEvent sends the heartbeat as a server-sent event and flushes it
+func writeEvent(w http.ResponseWriter, controller *http.ResponseController, id uint64, heartbeat Heartbeat) error {
+	data, err := json.Marshal(heartbeat)
+	if err != nil {
+		return fmt.Errorf("failed to encode heartbeat: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: heartbeat\ndata: %s\n\n", id, data); err != nil {
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	if err := controller.Flush(); err != nil {
+		return fmt.Errorf("failed to flush heartbeat: %w", err)
+	}
+	return nil
+}
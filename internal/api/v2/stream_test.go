@@ -0,0 +1,104 @@
+package v2
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readHeartbeat returns the next heartbeat event of the stream
+func readHeartbeat(t *testing.T, scanner *bufio.Scanner) Heartbeat {
+	t.Helper()
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var heartbeat Heartbeat
+		if err := json.Unmarshal([]byte(data), &heartbeat); err != nil {
+			t.Fatalf("failed to decode heartbeat %q: %v", data, err)
+		}
+		return heartbeat
+	}
+	t.Fatalf("stream ended: %v", scanner.Err())
+	return Heartbeat{}
+}
+
+func TestStreamHandler(t *testing.T) {
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+	server := httptest.NewServer(node.Track(StreamHandler(node)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?interval=100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for want := uint64(1); want <= 3; want++ {
+		heartbeat := readHeartbeat(t, scanner)
+		if heartbeat.Sequence != want {
+			t.Errorf("sequence = %d, want %d", heartbeat.Sequence, want)
+		}
+		if heartbeat.NodeID != node.ID || heartbeat.Hostname == "" {
+			t.Errorf("heartbeat = %+v, want the node %s", heartbeat, node.ID)
+		}
+		if heartbeat.InFlight != 1 {
+			t.Errorf("in_flight = %d, want the stream itself", heartbeat.InFlight)
+		}
+		if heartbeat.Draining {
+			t.Error("draining = true before the node drains")
+		}
+	}
+
+	// Draining sends a last heartbeat right away and ends the stream
+	start := time.Now()
+	node.Drain()
+	heartbeat := readHeartbeat(t, scanner)
+	if !heartbeat.Draining || heartbeat.Sequence != 4 {
+		t.Errorf("last heartbeat = %+v, want draining with sequence 4", heartbeat)
+	}
+	if scanner.Scan() && strings.HasPrefix(scanner.Text(), "data: ") {
+		t.Errorf("stream continued after draining: %q", scanner.Text())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stream took %v to end after draining", elapsed)
+	}
+}
+
+func TestStreamHandler_Interval(t *testing.T) {
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+
+	tests := []struct {
+		name     string
+		interval string
+	}{
+		{name: "not a duration", interval: "often"},
+		{name: "too short", interval: "1ms"},
+		{name: "too long", interval: "1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			StreamHandler(node).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/stream?interval="+tt.interval, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
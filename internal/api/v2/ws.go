@@ -0,0 +1,155 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	"github.com/ihatemodels/alcatraz-rest/internal/websocket"
+)
+
+const (
+	// closeTimeout is how long a draining node waits for the client to
+	// answer its close frame
+	closeTimeout = time.Second
+	// WSIdleTimeout closes connections on which the client sent no frame,
+	// pings included, for this long
+	WSIdleTimeout = time.Minute
+)
+
+// WSReply answers a text message of the WebSocket endpoint
+type WSReply struct {
+	Message  string `json:"message"`
+	Hostname string `json:"hostname"`
+	NodeID   string `json:"node_id"`
+	// Sequence counts the messages answered on the connection
+	Sequence uint64 `json:"sequence"`
+	// Received is the text message being answered
+	Received string `json:"received"`
+}
+
+// WSHandler returns the handler of the /api/v2/ws endpoint, it answers
+// every text message with the node hostname and every ping frame with a
+// pong until the client closes the connection, stays idle for
+// WSIdleTimeout or the node drains
+func WSHandler(node *Node) http.HandlerFunc {
+	return wsHandler(node, WSIdleTimeout)
+}
+
+func wsHandler(node *Node, idleTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hostname, err := os.Hostname()
+		if err != nil {
+			slog.Error("failed to get hostname", "error", err)
+			api.WriteProblem(w, r, http.StatusInternalServerError, "the node could not be described")
+			return
+		}
+
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			var handshakeErr *websocket.HandshakeError
+			if errors.As(err, &handshakeErr) {
+				if handshakeErr.Status == http.StatusUpgradeRequired {
+					w.Header().Set("Upgrade", "websocket")
+					w.Header().Set("Sec-WebSocket-Version", "13")
+				}
+				if handshakeErr.Status == http.StatusMethodNotAllowed {
+					w.Header().Set("Allow", http.MethodGet)
+				}
+				api.WriteProblem(w, r, handshakeErr.Status, handshakeErr.Detail)
+				return
+			}
+			slog.Error("failed to upgrade connection", "error", err)
+			return
+		}
+		defer conn.Close()
+		conn.IdleTimeout = idleTimeout
+
+		slog.Info("WebSocket connection opened", "hostname", hostname, "remote_addr", r.RemoteAddr)
+
+		// Say goodbye when the node drains, the client then reconnects
+		// through the load balancer to another node
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-node.Draining():
+				_ = conn.WriteClose(websocket.CloseGoingAway, "node draining")
+				_ = conn.SetReadDeadline(time.Now().Add(closeTimeout))
+			case <-done:
+			}
+		}()
+
+		var sequence uint64
+		for {
+			opcode, message, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				var netErr net.Error
+				timeout := errors.As(err, &netErr) && netErr.Timeout()
+				if timeout && conn.Closing() {
+					slog.Info("WebSocket close not answered", "hostname", hostname, "remote_addr", r.RemoteAddr, "messages", sequence)
+				} else if timeout {
+					_ = conn.WriteClose(websocket.CloseGoingAway, "idle timeout")
+					slog.Info("WebSocket connection idle", "hostname", hostname, "remote_addr", r.RemoteAddr, "messages", sequence)
+				} else if errors.As(err, &closeErr) {
+					slog.Info("WebSocket connection closed", "hostname", hostname, "remote_addr", r.RemoteAddr, "messages", sequence, "code", closeErr.Code)
+				} else {
+					slog.Info("WebSocket connection lost", "hostname", hostname, "remote_addr", r.RemoteAddr, "messages", sequence, "error", err)
+				}
+				return
+			}
+
+			// Once our close frame is sent only the answer of the client
+			// counts, messages that crossed it are dropped
+			if conn.Closing() {
+				continue
+			}
+			if opcode != websocket.TextMessage {
+				_ = conn.WriteClose(websocket.CloseUnsupportedData, "only text messages are supported")
+				_ = conn.SetReadDeadline(time.Now().Add(closeTimeout))
+				continue
+			}
+
+			sequence++
+			reply, err := json.Marshal(WSReply{
+				Message:  "pong",
+				Hostname: hostname,
+				NodeID:   node.ID,
+				Sequence: sequence,
+				Received: string(message),
+			})
+			if err != nil {
+				slog.Error("failed to encode reply", "error", err)
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, reply); err != nil {
+				// The node started draining while the reply was prepared
+				if conn.Closing() {
+					continue
+				}
+				slog.Info("WebSocket connection lost", "hostname", hostname, "remote_addr", r.RemoteAddr, "messages", sequence, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// WSRoutes returns the route of the WebSocket endpoint
+func WSRoutes(node *Node) []api.Route {
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/v2/ws",
+			OperationID: "wsV2",
+			Summary:     "WebSocket answering text messages with the node hostname and ping frames with pongs",
+			Handler:     WSHandler(node),
+			Status:      http.StatusSwitchingProtocols,
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/websocket"
+)
+
+func TestWSHandler(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+	server := httptest.NewServer(WSHandler(node))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), server.Client(), server.URL, nil, time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	for want := uint64(1); want <= 2; want++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v", err)
+		}
+
+		var reply WSReply
+		if err := json.Unmarshal(message, &reply); err != nil {
+			t.Fatalf("failed to decode reply %q: %v", message, err)
+		}
+		wantReply := WSReply{Message: "pong", Hostname: hostname, NodeID: node.ID, Sequence: want, Received: "hello"}
+		if reply != wantReply {
+			t.Errorf("reply = %+v, want %+v", reply, wantReply)
+		}
+	}
+
+	// Draining closes the connection with going away
+	node.Drain()
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("ReadMessage() after draining error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+}
+
+func TestWSHandler_NotUpgraded(t *testing.T) {
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+
+	rec := httptest.NewRecorder()
+	WSHandler(node).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/ws", nil))
+
+	if rec.Code != http.StatusUpgradeRequired {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUpgradeRequired)
+	}
+	if got := rec.Header().Get("Upgrade"); got != "websocket" {
+		t.Errorf("Upgrade = %q, want websocket", got)
+	}
+}
+
+func TestWSHandler_IdleTimeout(t *testing.T) {
+	const idleTimeout = 200 * time.Millisecond
+
+	node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+	server := httptest.NewServer(wsHandler(node, idleTimeout))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), server.Client(), server.URL, nil, time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	var pongs atomic.Int32
+	conn.OnPong = func([]byte) { pongs.Add(1) }
+
+	// Pings keep the connection open for longer than the idle timeout
+	start := time.Now()
+	for range 5 {
+		if err := conn.WriteMessage(websocket.PingMessage, []byte("alive")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(idleTimeout / 2)
+	}
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("ReadMessage() error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+	if elapsed := time.Since(start); elapsed < 5*idleTimeout/2 {
+		t.Errorf("connection closed after %v, want the pings to extend it", elapsed)
+	}
+	if got := pongs.Load(); got != 5 {
+		t.Errorf("received %d pongs, want 5", got)
+	}
+}
+
+func TestWSHandler_UnsupportedData(t *testing.T) {
+	tests := []struct {
+		name string
+		// answer reads the close frame of the node, which answers it
+		answer     bool
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		{name: "close answered", answer: true, maxElapsed: closeTimeout / 2},
+		{name: "close not answered", minElapsed: closeTimeout, maxElapsed: 2 * closeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NewNode("1.2.3", "0.0.0.0:8080", TLSModeDisabled)
+			handlerDone := make(chan struct{})
+			handler := WSHandler(node)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handlerDone)
+				handler(w, r)
+			}))
+			defer server.Close()
+
+			conn, _, err := websocket.Dial(context.Background(), server.Client(), server.URL, nil, time.Second)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			start := time.Now()
+			if err := conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3}); err != nil {
+				t.Fatal(err)
+			}
+			// A message crossing the close frame is not answered
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.answer {
+				_, _, err = conn.ReadMessage()
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseUnsupportedData {
+					t.Fatalf("ReadMessage() error = %v, want close %d", err, websocket.CloseUnsupportedData)
+				}
+			}
+
+			select {
+			case <-handlerDone:
+			case <-time.After(3 * closeTimeout):
+				t.Fatal("handler did not return after closing the connection")
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed || elapsed > tt.maxElapsed {
+				t.Errorf("handler returned after %v, want between %v and %v", elapsed, tt.minElapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
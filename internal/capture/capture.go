@@ -0,0 +1,274 @@
+package capture
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record describes a single captured request
+type Record struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	BodySHA256 string            `json:"body_sha256,omitempty"`
+	BodySize   int64             `json:"body_size"`
+	// BodyPartial marks bodies larger than MaxHashedBodySize the handler did
+	// not read completely, BodySize then counts the bytes read and the hash
+	// is left out
+	BodyPartial bool  `json:"body_partial,omitempty"`
+	Status      int   `json:"status"`
+	DurationUs  int64 `json:"duration_us"`
+}
+
+// secretHeaders are never written to the capture file
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// secretHeaderParts mark custom headers that most likely carry secrets
+var secretHeaderParts = []string{"token", "secret", "password", "session"}
+
+// secretParamParts mark query parameters that most likely carry secrets
+var secretParamParts = append([]string{"key", "auth", "signature", "credential"}, secretHeaderParts...)
+
+// redacted replaces the values of secret query parameters
+const redacted = "REDACTED"
+
+// MaxHashedBodySize limits how much of the body the middleware reads after
+// the handler to hash the rest of it, bodies the handler read are hashed
+// whatever their size
+const MaxHashedBodySize = 1 << 20
+
+// isSecret reports whether the header must be left out of the capture
+func isSecret(name string) bool {
+	if secretHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+
+	lower := strings.ToLower(name)
+	for _, part := range secretHeaderParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactQuery returns the path and query of the URL with the values of
+// secret parameters replaced, the order of the parameters is kept
+func redactQuery(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, ok := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if ok && isSecretParam(name) {
+			params[i] = key + "=" + redacted
+		}
+	}
+	return u.EscapedPath() + "?" + strings.Join(params, "&")
+}
+
+// isSecretParam reports whether the value of the query parameter must be
+// left out of the capture
+func isSecretParam(name string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range secretParamParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writer appends records to a capture file as newline delimited JSON,
+// it is safe for concurrent use
+type Writer struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewWriter creates a writer that writes records to w
+func NewWriter(w io.Writer) *Writer {
+	writer := &Writer{encoder: json.NewEncoder(w)}
+	if closer, ok := w.(io.Closer); ok {
+		writer.closer = closer
+	}
+	return writer
+}
+
+// Create opens the capture file for appending, creating it when needed
+func Create(filename string) (*Writer, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file %s: %w", filename, err)
+	}
+	return NewWriter(file), nil
+}
+
+// Write appends a single record
+func (w *Writer) Write(record Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write capture record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+// Middleware records every request handled by next to the writer
+func Middleware(w *Writer, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		body := &hashingReader{ReadCloser: r.Body, hash: sha256.New()}
+		r.Body = body
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		// Hash the part of the body the handler did not read, up to a limit
+		// so rejected or huge bodies are not read in full
+		if !body.eof && body.size <= MaxHashedBodySize {
+			if _, err := io.Copy(io.Discard, io.LimitReader(body, MaxHashedBodySize-body.size+1)); err != nil {
+				logger.Debug("failed to read remaining request body", "error", err)
+			}
+		}
+
+		record := Record{
+			Time:       start,
+			Method:     r.Method,
+			Path:       redactQuery(r.URL),
+			Headers:    make(map[string]string, len(r.Header)),
+			BodySize:   body.size,
+			Status:     recorder.status,
+			DurationUs: time.Since(start).Microseconds(),
+		}
+		switch {
+		case !body.eof:
+			record.BodyPartial = true
+		case body.size > 0:
+			record.BodySHA256 = hex.EncodeToString(body.hash.Sum(nil))
+		}
+		for name, values := range r.Header {
+			if isSecret(name) || name == "Content-Length" {
+				continue
+			}
+			record.Headers[name] = strings.Join(values, ", ")
+		}
+
+		if err := w.Write(record); err != nil {
+			logger.Error("failed to capture request", "error", err)
+		}
+	})
+}
+
+// Read decodes all records from r
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode capture record on line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture records: %w", err)
+	}
+
+	return records, nil
+}
+
+// ReadFile decodes all records from the capture file
+func ReadFile(filename string) ([]Record, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// hashingReader hashes and counts the bytes read from the request body
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	size int64
+	eof  bool
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.ReadCloser.Read(p)
+	h.hash.Write(p[:n])
+	h.size += int64(n)
+	if err == io.EOF {
+		h.eof = true
+	}
+	return n, err
+}
+
+// statusRecorder remembers the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,132 @@
+package capture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+
+	handler := Middleware(writer, slog.New(slog.DiscardHandler), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read only part of the body, the rest is hashed by the middleware
+		_, _ = io.ReadFull(r.Body, make([]byte, 2))
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	body := `{"order":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/orders?dry=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Session-Id", "abc")
+	req.Header.Add("Accept", "text/plain")
+	req.Header.Add("Accept", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("len(records) = %d, want 1", len(records))
+	}
+	record := records[0]
+
+	sum := sha256.Sum256([]byte(body))
+	if record.BodySHA256 != hex.EncodeToString(sum[:]) || record.BodySize != int64(len(body)) {
+		t.Errorf("body = %s (%d bytes), want hash of the full body", record.BodySHA256, record.BodySize)
+	}
+	if record.Method != http.MethodPost || record.Path != "/api/orders?dry=true" || record.Status != http.StatusCreated {
+		t.Errorf("record = %s %s %d, want POST /api/orders?dry=true 201", record.Method, record.Path, record.Status)
+	}
+	if _, ok := record.Headers["Authorization"]; ok {
+		t.Error("Authorization header was captured")
+	}
+	if _, ok := record.Headers["X-Session-Id"]; ok {
+		t.Error("X-Session-Id header was captured")
+	}
+	if record.Headers["Accept"] != "text/plain, application/json" {
+		t.Errorf("Accept = %q, want both values", record.Headers["Accept"])
+	}
+}
+
+func TestRead_InvalidLine(t *testing.T) {
+	_, err := Read(strings.NewReader("{\"method\":\"GET\"}\n\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Read() error = %v, want error on line 3", err)
+	}
+}
+
+func TestMiddleware_LargeBody(t *testing.T) {
+	large := strings.Repeat("a", MaxHashedBodySize+1)
+
+	tests := []struct {
+		name        string
+		readAll     bool
+		wantSize    int64
+		wantPartial bool
+	}{
+		{name: "read by the handler", readAll: true, wantSize: int64(len(large))},
+		{name: "left unread", wantSize: MaxHashedBodySize + 1, wantPartial: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := Middleware(NewWriter(&buf), slog.New(slog.DiscardHandler), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.readAll {
+					_, _ = io.Copy(io.Discard, r.Body)
+				}
+			}))
+			// The reader is longer than the limit so unread bodies are cut off
+			body := io.MultiReader(strings.NewReader(large), strings.NewReader(large))
+			if tt.readAll {
+				body = strings.NewReader(large)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/upload", body))
+
+			records, err := Read(&buf)
+			if err != nil || len(records) != 1 {
+				t.Fatalf("Read() = %d records, %v, want 1", len(records), err)
+			}
+			record := records[0]
+			if record.BodySize != tt.wantSize || record.BodyPartial != tt.wantPartial || (record.BodySHA256 == "") != tt.wantPartial {
+				t.Errorf("body = %d bytes, partial %v, hash %q, want %d bytes, partial %v",
+					record.BodySize, record.BodyPartial, record.BodySHA256, tt.wantSize, tt.wantPartial)
+			}
+		})
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{name: "no query", target: "/api/ping", want: "/api/ping"},
+		{name: "plain parameters", target: "/api/orders?dry=true&page=2", want: "/api/orders?dry=true&page=2"},
+		{
+			name:   "secret parameters",
+			target: "/api/orders?page=2&access_token=abc&api_key=def&X-Amz-Signature=ghi",
+			want:   "/api/orders?page=2&access_token=REDACTED&api_key=REDACTED&X-Amz-Signature=REDACTED",
+		},
+		{name: "escaped name", target: "/search?q=a%20b&pass%77ord=x", want: "/search?q=a%20b&pass%77ord=REDACTED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactQuery(httptest.NewRequest(http.MethodGet, tt.target, nil).URL); got != tt.want {
+				t.Errorf("redactQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/ihatemodels/alcatraz-rest/internal/observability"
 	"gopkg.in/yaml.v3"
@@ -11,8 +14,11 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	log    LogConfig    `yaml:"log"`
+	Server  ServerConfig  `yaml:"server"`
+	log     LogConfig     `yaml:"log"`
+	Capture CaptureConfig `yaml:"capture"`
+	Faults  FaultsConfig  `yaml:"faults"`
+	Node    NodeConfig    `yaml:"node"`
 
 	// Observability configuration
 	// set from the config values
@@ -21,9 +27,12 @@ type Config struct {
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	ListenAddress string    `yaml:"listen_address"`
-	Port          int       `yaml:"port"`
-	TLS           TLSConfig `yaml:"tls"`
+	ListenAddress string        `yaml:"listen_address"`
+	Port          int           `yaml:"port"`
+	TLS           TLSConfig     `yaml:"tls"`
+	Echo          EchoConfig    `yaml:"echo"`
+	GRPC          GRPCConfig    `yaml:"grpc"`
+	Headers       HeadersConfig `yaml:"headers"`
 }
 
 // TLSConfig holds TLS-related configuration
@@ -35,6 +44,89 @@ type TLSConfig struct {
 	RequireClientCert bool   `yaml:"require_client_cert"`
 }
 
+// EchoConfig holds the configuration of the echo endpoint, it exposes the
+// request internals and is disabled by default
+type EchoConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	RedactHeaders  []string `yaml:"redact_headers"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Proxies parses the trusted proxies, single addresses are accepted as well
+func (e EchoConfig) Proxies() ([]netip.Prefix, error) {
+	if len(e.TrustedProxies) == 0 {
+		return nil, nil
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(e.TrustedProxies))
+	for _, value := range e.TrustedProxies {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// GRPCConfig holds the configuration of the gRPC API, it listens on its
+// own port with the TLS configuration of the REST API
+type GRPCConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"`
+	// Reflection lists the services to clients such as grpcurl
+	Reflection bool `yaml:"reflection"`
+}
+
+// HeadersConfig names the headers identifying the node on every response,
+// empty names leave a header out
+type HeadersConfig struct {
+	ServedBy     string `yaml:"served_by"`
+	NodeVersion  string `yaml:"node_version"`
+	ServerTiming bool   `yaml:"server_timing"`
+}
+
+// headerNamePattern matches valid HTTP header names (RFC 9110 tokens)
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// CaptureConfig holds request capture configuration
+type CaptureConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	File    string `yaml:"file"`
+}
+
+// FaultsConfig holds the fault injection configuration, it is meant for
+// resilience tests and disabled by default
+type FaultsConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	AdminToken string `yaml:"admin_token"`
+}
+
+// NodeConfig holds the identity of the node returned by the API, the
+// hostname is a random container ID in Docker
+type NodeConfig struct {
+	// Name defaults to the hostname
+	Name   string            `yaml:"name"`
+	Zone   string            `yaml:"zone"`
+	Region string            `yaml:"region"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// labelKeyPattern matches the allowed label keys
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// validLabelValue reports whether the value can be written to the node
+// header, which separates labels with ";" and keys from values with "="
+func validLabelValue(value string) bool {
+	return !strings.ContainsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == '=' || r < ' ' || r == 0x7f
+	})
+}
+
 // LogConfig holds logging-related configuration
 type LogConfig struct {
 	Level string `yaml:"level"`
@@ -47,6 +139,10 @@ const (
 	DefaultPort          = 8080
 	DefaultLogLevel      = "info"
 	DefaultLogType       = "json"
+	DefaultGRPCPort      = 9090
+
+	DefaultServedByHeader    = "X-Served-By"
+	DefaultNodeVersionHeader = "X-Node-Version"
 )
 
 // LoadConfig loads configuration from YAML file and command line flags
@@ -59,6 +155,7 @@ func LoadConfig() (*Config, error) {
 		port          = flag.Int("port", 0, "Server port")
 		logLevel      = flag.String("log-level", "", "Log level (debug, info, warn, error)")
 		logType       = flag.String("log-type", "", "Log type (console, json)")
+		captureFile   = flag.String("capture-file", "", "Capture every request to this NDJSON file")
 		help          = flag.Bool("help", false, "Show help message")
 	)
 	flag.Parse()
@@ -73,6 +170,14 @@ func LoadConfig() (*Config, error) {
 		Server: ServerConfig{
 			ListenAddress: DefaultListenAddress,
 			Port:          DefaultPort,
+			GRPC: GRPCConfig{
+				Port: DefaultGRPCPort,
+			},
+			Headers: HeadersConfig{
+				ServedBy:     DefaultServedByHeader,
+				NodeVersion:  DefaultNodeVersionHeader,
+				ServerTiming: true,
+			},
 		},
 		log: LogConfig{
 			Level: DefaultLogLevel,
@@ -87,6 +192,10 @@ func LoadConfig() (*Config, error) {
 
 	// Override with command line flags if provided
 	applyFlags(config, listenAddress, port, logLevel, logType)
+	if *captureFile != "" {
+		config.Capture.Enabled = true
+		config.Capture.File = *captureFile
+	}
 
 	// Set observability configuration from log config
 	config.setObservabilityConfig()
@@ -184,6 +293,57 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate echo configuration
+	if _, err := c.Server.Echo.Proxies(); err != nil {
+		return err
+	}
+
+	// Validate gRPC configuration if enabled
+	if c.Server.GRPC.Enabled {
+		if c.Server.GRPC.Port < 1 || c.Server.GRPC.Port > 65535 {
+			return fmt.Errorf("invalid gRPC port: %d (must be between 1 and 65535)", c.Server.GRPC.Port)
+		}
+		if c.Server.GRPC.Port == c.Server.Port {
+			return fmt.Errorf("gRPC port %d must differ from the server port", c.Server.GRPC.Port)
+		}
+	}
+
+	// Validate response headers
+	for _, name := range []string{c.Server.Headers.ServedBy, c.Server.Headers.NodeVersion} {
+		if name != "" && !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid response header name %q", name)
+		}
+	}
+
+	// Validate node metadata
+	for name, value := range map[string]string{"node name": c.Node.Name, "node zone": c.Node.Zone, "node region": c.Node.Region} {
+		if !validLabelValue(value) {
+			return fmt.Errorf("invalid %s %q: must not contain ';', ',', '=' or control characters", name, value)
+		}
+	}
+	for key, value := range c.Node.Labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid node label key %q: must start with a letter or digit and contain only letters, digits, '.', '_', '/' and '-'", key)
+		}
+		if key == "name" || key == "zone" || key == "region" {
+			return fmt.Errorf("invalid node label key %q: reserved, use the node %s setting", key, key)
+		}
+		if !validLabelValue(value) {
+			return fmt.Errorf("invalid node label %s value %q: must not contain ';', ',', '=' or control characters", key, value)
+		}
+	}
+
+	// Validate fault injection configuration if enabled, the admin API can
+	// take the node down and must not be open to everyone
+	if c.Faults.Enabled && c.Faults.AdminToken == "" {
+		return fmt.Errorf("faults admin token cannot be empty when fault injection is enabled")
+	}
+
+	// Validate capture configuration if enabled
+	if c.Capture.Enabled && c.Capture.File == "" {
+		return fmt.Errorf("capture file cannot be empty when capture is enabled")
+	}
+
 	return nil
 }
 
@@ -192,6 +352,11 @@ func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.ListenAddress, c.Server.Port)
 }
 
+// GetGRPCAddress returns the address of the gRPC API (host:port)
+func (c *Config) GetGRPCAddress() string {
+	return fmt.Sprintf("%s:%d", c.Server.ListenAddress, c.Server.GRPC.Port)
+}
+
 func (c *Config) setObservabilityConfig() {
 	c.Observability.Format = observability.OutputFormat(c.log.Type)
 	c.Observability.Level = observability.LogLevel(c.log.Level)
@@ -198,6 +198,226 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "listen address cannot be empty",
 		},
+		{
+			name: "invalid capture config - missing file",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Capture: CaptureConfig{
+					Enabled: true,
+				},
+			},
+			wantErr: true,
+			errMsg:  "capture file cannot be empty",
+		},
+		{
+			name: "invalid gRPC config - same port as the server",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+					GRPC: GRPCConfig{
+						Enabled: true,
+						Port:    8080,
+					},
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+			},
+			wantErr: true,
+			errMsg:  "gRPC port 8080 must differ",
+		},
+		{
+			name: "invalid gRPC config - port out of range",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+					GRPC: GRPCConfig{
+						Enabled: true,
+						Port:    70000,
+					},
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid gRPC port",
+		},
+		{
+			name: "valid headers config - headers left out",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+					Headers: HeadersConfig{
+						ServedBy: "X-Backend",
+					},
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid headers config - bad header name",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+					Headers: HeadersConfig{
+						ServedBy: "X-Served By",
+					},
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid response header name",
+		},
+		{
+			name: "invalid faults config - missing admin token",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Faults: FaultsConfig{
+					Enabled: true,
+				},
+			},
+			wantErr: true,
+			errMsg:  "faults admin token cannot be empty",
+		},
+		{
+			name: "invalid echo config - bad trusted proxy",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+					Echo: EchoConfig{
+						Enabled:        true,
+						TrustedProxies: []string{"10.0.0.0/8", "caddy"},
+					},
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid trusted proxy",
+		},
+		{
+			name: "valid node config",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Node: NodeConfig{
+					Name:   "node-1",
+					Zone:   "eu-central-1a",
+					Region: "eu-central-1",
+					Labels: map[string]string{"team": "edge", "example.com/tier": "gold"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid node config - bad label key",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Node: NodeConfig{
+					Labels: map[string]string{"-team": "edge"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node label key",
+		},
+		{
+			name: "invalid node config - reserved label key",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Node: NodeConfig{
+					Labels: map[string]string{"zone": "eu-central-1a"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node label key \"zone\": reserved",
+		},
+		{
+			name: "invalid node config - separator in label value",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Node: NodeConfig{
+					Labels: map[string]string{"team": "edge; zone=b"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node label team value",
+		},
+		{
+			name: "invalid node config - separator in name",
+			config: Config{
+				Server: ServerConfig{
+					ListenAddress: "0.0.0.0",
+					Port:          8080,
+				},
+				log: LogConfig{
+					Level: "info",
+					Type:  "json",
+				},
+				Node: NodeConfig{
+					Name: "node=1",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node name",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,260 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// defaultSlowChunk is the number of bytes written at once by slow bodies
+const defaultSlowChunk = 16
+
+// Fault describes how a node misbehaves, the rates are probabilities
+// between 0 and 1 applied to every request independently
+type Fault struct {
+	// Latency delays every response, Jitter adds a random delay up to its value
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate of the responses fail with ErrorStatus (503 by default)
+	ErrorRate   float64
+	ErrorStatus int
+	// ResetRate of the connections are reset instead of answered
+	ResetRate float64
+	// HangRate of the requests are never answered
+	HangRate float64
+	// SlowBody delays every SlowChunk bytes of the response body
+	SlowBody  time.Duration
+	SlowChunk int
+}
+
+// Parse reads a fault from query parameters: latency, jitter, error_rate,
+// error_status, reset_rate, hang_rate, slow_body and slow_chunk
+func Parse(values url.Values) (Fault, error) {
+	var f Fault
+	var err error
+
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"latency", &f.Latency},
+		{"jitter", &f.Jitter},
+		{"slow_body", &f.SlowBody},
+	}
+	for _, d := range durations {
+		if *d.value, err = parseDuration(values, d.name); err != nil {
+			return Fault{}, err
+		}
+	}
+
+	rates := []struct {
+		name  string
+		value *float64
+	}{
+		{"error_rate", &f.ErrorRate},
+		{"reset_rate", &f.ResetRate},
+		{"hang_rate", &f.HangRate},
+	}
+	for _, r := range rates {
+		if *r.value, err = parseRate(values, r.name); err != nil {
+			return Fault{}, err
+		}
+	}
+
+	if f.ErrorStatus, err = parseInt(values, "error_status"); err != nil {
+		return Fault{}, err
+	}
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599) {
+		return Fault{}, fmt.Errorf("invalid error_status: %d (must be between 400 and 599)", f.ErrorStatus)
+	}
+	if f.SlowChunk, err = parseInt(values, "slow_chunk"); err != nil {
+		return Fault{}, err
+	}
+	if f.SlowChunk < 0 {
+		return Fault{}, fmt.Errorf("invalid slow_chunk: %d (must not be negative)", f.SlowChunk)
+	}
+
+	return f, nil
+}
+
+// Values returns the query parameters Parse reads the fault from
+func (f Fault) Values() url.Values {
+	values := url.Values{}
+	for name, d := range map[string]time.Duration{"latency": f.Latency, "jitter": f.Jitter, "slow_body": f.SlowBody} {
+		if d > 0 {
+			values.Set(name, d.String())
+		}
+	}
+	for name, rate := range map[string]float64{"error_rate": f.ErrorRate, "reset_rate": f.ResetRate, "hang_rate": f.HangRate} {
+		if rate > 0 {
+			values.Set(name, strconv.FormatFloat(rate, 'f', -1, 64))
+		}
+	}
+	if f.ErrorStatus != 0 {
+		values.Set("error_status", strconv.Itoa(f.ErrorStatus))
+	}
+	if f.SlowChunk != 0 {
+		values.Set("slow_chunk", strconv.Itoa(f.SlowChunk))
+	}
+	return values
+}
+
+// IsZero reports whether the fault leaves requests alone
+func (f Fault) IsZero() bool {
+	return f.Latency == 0 && f.Jitter == 0 && f.ErrorRate == 0 && f.ResetRate == 0 && f.HangRate == 0 && f.SlowBody == 0
+}
+
+// Apply serves the request with next while injecting the fault
+func (f Fault) Apply(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if chance(f.HangRate) {
+		// Hold the request until the client or the server gives up
+		<-r.Context().Done()
+		return
+	}
+
+	if chance(f.ResetRate) {
+		reset(w)
+		return
+	}
+
+	delay := f.Latency
+	if f.Jitter > 0 {
+		delay += rand.N(f.Jitter)
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+
+	if chance(f.ErrorRate) {
+		status := f.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		api.WriteProblem(w, r, status, "injected fault")
+		return
+	}
+
+	if f.SlowBody > 0 {
+		chunk := f.SlowChunk
+		if chunk == 0 {
+			chunk = defaultSlowChunk
+		}
+		w = &slowWriter{ResponseWriter: w, ctx: r.Context(), delay: f.SlowBody, chunk: chunk}
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// chance reports true with the probability rate
+func chance(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// reset closes the connection without a response, with a TCP reset where
+// possible, HTTP/2 connections cannot be hijacked and get the stream reset
+func reset(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	raw := conn
+	if netConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		raw = netConn.NetConn()
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok {
+		// Discard unsent data so closing sends RST instead of FIN
+		_ = tcp.SetLinger(0)
+	}
+	_ = raw.Close()
+}
+
+// slowWriter writes the body in chunks with a delay before every chunk
+type slowWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	delay time.Duration
+	chunk int
+}
+
+func (s *slowWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(s.chunk, len(p))
+
+		timer := time.NewTimer(s.delay)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return written, s.ctx.Err()
+		}
+
+		m, err := s.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		// Push every chunk to the client instead of buffering it
+		if err := http.NewResponseController(s.ResponseWriter).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Unwrap gives http.ResponseController access to the original writer
+func (s *slowWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func parseDuration(values url.Values, name string) (time.Duration, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q (must be a positive duration like 200ms)", name, value)
+	}
+	return d, nil
+}
+
+func parseRate(values url.Values, name string) (float64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid %s: %q (must be between 0 and 1)", name, value)
+	}
+	return rate, nil
+}
+
+func parseInt(values url.Values, name string) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q (must be a number)", name, value)
+	}
+	return n, nil
+}
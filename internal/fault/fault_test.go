@@ -0,0 +1,193 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("0123456789"))
+})
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Fault
+		wantErr bool
+	}{
+		{name: "no fault", query: ""},
+		{
+			name:  "every parameter",
+			query: "latency=100ms&jitter=50ms&error_rate=0.5&error_status=502&reset_rate=0.1&hang_rate=0.01&slow_body=10ms&slow_chunk=4",
+			want: Fault{
+				Latency: 100 * time.Millisecond, Jitter: 50 * time.Millisecond,
+				ErrorRate: 0.5, ErrorStatus: 502, ResetRate: 0.1, HangRate: 0.01,
+				SlowBody: 10 * time.Millisecond, SlowChunk: 4,
+			},
+		},
+		{name: "invalid duration", query: "latency=fast", wantErr: true},
+		{name: "negative duration", query: "latency=-1s", wantErr: true},
+		{name: "rate above one", query: "error_rate=1.5", wantErr: true},
+		{name: "success status", query: "error_status=200", wantErr: true},
+		{name: "negative chunk", query: "slow_chunk=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			got, err := Parse(values)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q) error = nil, want error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+
+			// The query form of the fault parses back to the same fault
+			if again, err := Parse(got.Values()); err != nil || again != got {
+				t.Errorf("Parse(Values()) = %+v, %v, want %+v", again, err, got)
+			}
+		})
+	}
+}
+
+func TestFault_Apply(t *testing.T) {
+	tests := []struct {
+		name       string
+		fault      Fault
+		wantStatus int
+		wantBody   string
+		minElapsed time.Duration
+	}{
+		{name: "no fault", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "latency", fault: Fault{Latency: 30 * time.Millisecond}, wantStatus: http.StatusOK, minElapsed: 30 * time.Millisecond},
+		{name: "error", fault: Fault{ErrorRate: 1}, wantStatus: http.StatusServiceUnavailable},
+		{name: "error status", fault: Fault{ErrorRate: 1, ErrorStatus: 500}, wantStatus: http.StatusInternalServerError},
+		{
+			name:       "slow body",
+			fault:      Fault{SlowBody: 10 * time.Millisecond, SlowChunk: 4},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+			minElapsed: 30 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			tt.fault.Apply(rec, httptest.NewRequest(http.MethodGet, "/", nil), okHandler)
+
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("elapsed = %v, want at least %v", elapsed, tt.minElapsed)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestFault_Hang(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	Fault{HangRate: 1}.Apply(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), okHandler)
+
+	if ctx.Err() == nil {
+		t.Error("Apply() returned before the request was given up")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", rec.Body.String())
+	}
+}
+
+func TestFault_Reset(t *testing.T) {
+	server := httptest.NewServer(Handler(okHandler))
+	defer server.Close()
+
+	if _, err := server.Client().Get(server.URL + "?reset_rate=1"); err == nil {
+		t.Error("Get() error = nil, want the connection to be reset")
+	}
+}
+
+func TestInjector(t *testing.T) {
+	injector := NewInjector("secret")
+	router := http.NewServeMux()
+	for _, route := range injector.Routes() {
+		router.Handle(route.Method+" "+route.Path, route.Handler)
+	}
+	router.Handle("/", okHandler)
+	handler := injector.Middleware(router)
+
+	do := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, AdminPath+"?error_rate=1&duration=1m", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST with a wrong token status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := do(http.MethodPost, AdminPath+"?duration=1m", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST without a fault status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := do(http.MethodPost, AdminPath+"?error_rate=1", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST without a duration status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec := do(http.MethodPost, AdminPath+"?error_rate=1&duration=1m", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if status := injector.Status(); !status.Active || status.Fault != "error_rate=1" {
+		t.Errorf("Status() = %+v, want the active error fault", status)
+	}
+	if rec := do(http.MethodGet, "/api/ping", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request during the fault status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec := do(http.MethodGet, AdminPath, "secret"); rec.Code != http.StatusOK {
+		t.Errorf("admin API during the fault status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if rec := do(http.MethodDelete, AdminPath, "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := do(http.MethodGet, "/api/ping", ""); rec.Code != http.StatusOK {
+		t.Errorf("request after the fault status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Without a token the admin API is closed to everyone
+	for _, route := range NewInjector("").Routes() {
+		rec := httptest.NewRecorder()
+		route.Handler.ServeHTTP(rec, httptest.NewRequest(route.Method, route.Path+"?error_rate=1&duration=1m", nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s without a configured token status = %d, want %d", route.Method, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	// The fault ends by itself when its window is over
+	injector.Set(Fault{ErrorRate: 1}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if rec := do(http.MethodGet, "/api/ping", ""); rec.Code != http.StatusOK {
+		t.Errorf("request after the window status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
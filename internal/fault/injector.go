@@ -0,0 +1,224 @@
+package fault
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// AdminPath is the path of the admin API, faults are never applied to it
+const AdminPath = "/admin/faults"
+
+// Status describes the fault applied to all requests
+type Status struct {
+	Active bool `json:"active"`
+	// Fault holds the query parameters of the fault
+	Fault string     `json:"fault,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// Injector applies a fault to every request for a time window, the fault
+// is managed through the admin API
+type Injector struct {
+	mu    sync.Mutex
+	fault Fault
+	until time.Time
+
+	// token protects the admin API when set
+	token string
+}
+
+// NewInjector creates an injector without an active fault, the admin API
+// requires the bearer token and refuses every request when it is empty
+func NewInjector(token string) *Injector {
+	return &Injector{token: token}
+}
+
+// Set applies the fault to all requests for the duration
+func (i *Injector) Set(f Fault, duration time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.fault = f
+	i.until = time.Now().Add(duration)
+}
+
+// Clear stops the active fault
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.fault = Fault{}
+	i.until = time.Time{}
+}
+
+// active returns the fault that applies now
+func (i *Injector) active() (Fault, time.Time, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.fault.IsZero() || !time.Now().Before(i.until) {
+		return Fault{}, time.Time{}, false
+	}
+	return i.fault, i.until, true
+}
+
+// Status describes the active fault
+func (i *Injector) Status() Status {
+	f, until, ok := i.active()
+	if !ok {
+		return Status{}
+	}
+	return Status{Active: true, Fault: f.Values().Encode(), Until: &until}
+}
+
+// Middleware applies the active fault to the requests handled by next,
+// except to the admin API so a fault can always be stopped
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, ok := i.active()
+		if !ok || strings.HasPrefix(r.URL.Path, AdminPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		f.Apply(w, r, next)
+	})
+}
+
+// Handler injects the fault described by the query parameters of every
+// request into next
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := Parse(r.URL.Query())
+		if err != nil {
+			api.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.Apply(w, r, next)
+	})
+}
+
+// Parameters documents the query parameters of a fault
+func Parameters() []api.Parameter {
+	duration := api.Schema{"type": "string", "examples": []string{"200ms"}}
+	rate := api.Schema{"type": "number", "minimum": 0, "maximum": 1}
+	return []api.Parameter{
+		{Name: "latency", In: "query", Description: "Fixed delay before the response", Schema: duration},
+		{Name: "jitter", In: "query", Description: "Random delay up to this value added to the latency", Schema: duration},
+		{Name: "error_rate", In: "query", Description: "Share of responses that fail", Schema: rate},
+		{Name: "error_status", In: "query", Description: "Status of the failed responses, 503 by default", Schema: api.Schema{"type": "integer"}},
+		{Name: "reset_rate", In: "query", Description: "Share of connections reset without a response", Schema: rate},
+		{Name: "hang_rate", In: "query", Description: "Share of requests that are never answered", Schema: rate},
+		{Name: "slow_body", In: "query", Description: "Delay before every chunk of the response body", Schema: duration},
+		{Name: "slow_chunk", In: "query", Description: "Bytes per chunk of a slow body, 16 by default", Schema: api.Schema{"type": "integer"}},
+	}
+}
+
+// Routes returns the routes of the admin API
+func (i *Injector) Routes() []api.Route {
+	windowParameter := api.Parameter{
+		Name:        "duration",
+		In:          "query",
+		Description: "How long the fault is applied",
+		Schema:      api.Schema{"type": "string", "examples": []string{"30s"}},
+	}
+
+	return []api.Route{
+		{
+			Method:      http.MethodGet,
+			Path:        AdminPath,
+			OperationID: "getFaults",
+			Summary:     "The fault applied to all requests",
+			Handler:     i.authorize(i.serveStatus),
+			Response:    Status{},
+			ContentType: "application/json",
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        AdminPath,
+			OperationID: "setFaults",
+			Summary:     "Apply a fault to all requests for a time window",
+			Parameters:  append(Parameters(), windowParameter),
+			Handler:     i.authorize(i.serveSet),
+			Response:    Status{},
+			ContentType: "application/json",
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        AdminPath,
+			OperationID: "clearFaults",
+			Summary:     "Stop the fault applied to all requests",
+			Handler:     i.authorize(i.serveClear),
+			Response:    Status{},
+			ContentType: "application/json",
+		},
+	}
+}
+
+func (i *Injector) serveStatus(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, i.Status())
+}
+
+func (i *Injector) serveSet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	f, err := Parse(query)
+	if err != nil {
+		api.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.IsZero() {
+		api.WriteProblem(w, r, http.StatusBadRequest, "no fault given")
+		return
+	}
+
+	duration, err := time.ParseDuration(query.Get("duration"))
+	if err != nil || duration <= 0 {
+		api.WriteProblem(w, r, http.StatusBadRequest,
+			fmt.Sprintf("invalid duration: %q (must be a positive duration like 30s)", query.Get("duration")))
+		return
+	}
+
+	i.Set(f, duration)
+	slog.Warn("fault injection started", "fault", f.Values().Encode(), "duration", duration, "remote_addr", r.RemoteAddr)
+	writeStatus(w, i.Status())
+}
+
+func (i *Injector) serveClear(w http.ResponseWriter, r *http.Request) {
+	i.Clear()
+	slog.Warn("fault injection stopped", "remote_addr", r.RemoteAddr)
+	writeStatus(w, i.Status())
+}
+
+// authorize requires the bearer token, without a configured token every
+// request is refused so the admin API is never open to anyone
+func (i *Injector) authorize(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i.token == "" {
+			api.WriteProblem(w, r, http.StatusForbidden, "the admin API is disabled without an admin token")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(i.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			api.WriteProblem(w, r, http.StatusUnauthorized, "the admin API requires the configured bearer token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func writeStatus(w http.ResponseWriter, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.Error("failed to encode fault status", "error", err)
+	}
+}
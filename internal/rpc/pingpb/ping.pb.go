@@ -0,0 +1,503 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: alcatraz/v2/ping.proto
+
+package pingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_alcatraz_v2_ping_proto_rawDescGZIP(), []int{0}
+}
+
+// PingResponse carries the fields of the v2 REST ping response
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Hostname      string                 `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	NodeId        string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Version       string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	UptimeSeconds float64                `protobuf:"fixed64,6,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	Runtime       *RuntimeInfo           `protobuf:"bytes,7,opt,name=runtime,proto3" json:"runtime,omitempty"`
+	ListenAddress string                 `protobuf:"bytes,8,opt,name=listen_address,json=listenAddress,proto3" json:"listen_address,omitempty"`
+	TlsMode       string                 `protobuf:"bytes,9,opt,name=tls_mode,json=tlsMode,proto3" json:"tls_mode,omitempty"`
+	// client is the verified client certificate, unset without one
+	Client        *ClientIdentity `protobuf:"bytes,10,opt,name=client,proto3" json:"client,omitempty"`
+	Node          *NodeMetadata   `protobuf:"bytes,11,opt,name=node,proto3" json:"node,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_alcatraz_v2_ping_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PingResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *PingResponse) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *PingResponse) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *PingResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *PingResponse) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *PingResponse) GetUptimeSeconds() float64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *PingResponse) GetRuntime() *RuntimeInfo {
+	if x != nil {
+		return x.Runtime
+	}
+	return nil
+}
+
+func (x *PingResponse) GetListenAddress() string {
+	if x != nil {
+		return x.ListenAddress
+	}
+	return ""
+}
+
+func (x *PingResponse) GetTlsMode() string {
+	if x != nil {
+		return x.TlsMode
+	}
+	return ""
+}
+
+func (x *PingResponse) GetClient() *ClientIdentity {
+	if x != nil {
+		return x.Client
+	}
+	return nil
+}
+
+func (x *PingResponse) GetNode() *NodeMetadata {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+type RuntimeInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GoVersion     string                 `protobuf:"bytes,1,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	Os            string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`
+	Arch          string                 `protobuf:"bytes,3,opt,name=arch,proto3" json:"arch,omitempty"`
+	NumCpu        int32                  `protobuf:"varint,4,opt,name=num_cpu,json=numCpu,proto3" json:"num_cpu,omitempty"`
+	NumGoroutine  int32                  `protobuf:"varint,5,opt,name=num_goroutine,json=numGoroutine,proto3" json:"num_goroutine,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RuntimeInfo) Reset() {
+	*x = RuntimeInfo{}
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuntimeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuntimeInfo) ProtoMessage() {}
+
+func (x *RuntimeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuntimeInfo.ProtoReflect.Descriptor instead.
+func (*RuntimeInfo) Descriptor() ([]byte, []int) {
+	return file_alcatraz_v2_ping_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RuntimeInfo) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *RuntimeInfo) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *RuntimeInfo) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *RuntimeInfo) GetNumCpu() int32 {
+	if x != nil {
+		return x.NumCpu
+	}
+	return 0
+}
+
+func (x *RuntimeInfo) GetNumGoroutine() int32 {
+	if x != nil {
+		return x.NumGoroutine
+	}
+	return 0
+}
+
+type ClientIdentity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subject       string                 `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	Issuer        string                 `protobuf:"bytes,2,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	SerialNumber  string                 `protobuf:"bytes,3,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	NotAfter      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientIdentity) Reset() {
+	*x = ClientIdentity{}
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientIdentity) ProtoMessage() {}
+
+func (x *ClientIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientIdentity.ProtoReflect.Descriptor instead.
+func (*ClientIdentity) Descriptor() ([]byte, []int) {
+	return file_alcatraz_v2_ping_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClientIdentity) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *ClientIdentity) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *ClientIdentity) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *ClientIdentity) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+// NodeMetadata is the configured identity of the node
+type NodeMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Zone          string                 `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region        string                 `protobuf:"bytes,3,opt,name=region,proto3" json:"region,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeMetadata) Reset() {
+	*x = NodeMetadata{}
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeMetadata) ProtoMessage() {}
+
+func (x *NodeMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_alcatraz_v2_ping_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeMetadata.ProtoReflect.Descriptor instead.
+func (*NodeMetadata) Descriptor() ([]byte, []int) {
+	return file_alcatraz_v2_ping_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NodeMetadata) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *NodeMetadata) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *NodeMetadata) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *NodeMetadata) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+var File_alcatraz_v2_ping_proto protoreflect.FileDescriptor
+
+const file_alcatraz_v2_ping_proto_rawDesc = "" +
+	"\n" +
+	"\x16alcatraz/v2/ping.proto\x12\valcatraz.v2\x1a\x1fgoogle/protobuf/timestamp.proto\"\r\n" +
+	"\vPingRequest\"\xb3\x03\n" +
+	"\fPingResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\x129\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x12%\n" +
+	"\x0euptime_seconds\x18\x06 \x01(\x01R\ruptimeSeconds\x122\n" +
+	"\aruntime\x18\a \x01(\v2\x18.alcatraz.v2.RuntimeInfoR\aruntime\x12%\n" +
+	"\x0elisten_address\x18\b \x01(\tR\rlistenAddress\x12\x19\n" +
+	"\btls_mode\x18\t \x01(\tR\atlsMode\x123\n" +
+	"\x06client\x18\n" +
+	" \x01(\v2\x1b.alcatraz.v2.ClientIdentityR\x06client\x12-\n" +
+	"\x04node\x18\v \x01(\v2\x19.alcatraz.v2.NodeMetadataR\x04node\"\x8e\x01\n" +
+	"\vRuntimeInfo\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x01 \x01(\tR\tgoVersion\x12\x0e\n" +
+	"\x02os\x18\x02 \x01(\tR\x02os\x12\x12\n" +
+	"\x04arch\x18\x03 \x01(\tR\x04arch\x12\x17\n" +
+	"\anum_cpu\x18\x04 \x01(\x05R\x06numCpu\x12#\n" +
+	"\rnum_goroutine\x18\x05 \x01(\x05R\fnumGoroutine\"\xa0\x01\n" +
+	"\x0eClientIdentity\x12\x18\n" +
+	"\asubject\x18\x01 \x01(\tR\asubject\x12\x16\n" +
+	"\x06issuer\x18\x02 \x01(\tR\x06issuer\x12#\n" +
+	"\rserial_number\x18\x03 \x01(\tR\fserialNumber\x127\n" +
+	"\tnot_after\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bnotAfter\"\xc8\x01\n" +
+	"\fNodeMetadata\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x16\n" +
+	"\x06region\x18\x03 \x01(\tR\x06region\x12=\n" +
+	"\x06labels\x18\x04 \x03(\v2%.alcatraz.v2.NodeMetadata.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012J\n" +
+	"\vPingService\x12;\n" +
+	"\x04Ping\x12\x18.alcatraz.v2.PingRequest\x1a\x19.alcatraz.v2.PingResponseB:Z8github.com/ihatemodels/alcatraz-rest/internal/rpc/pingpbb\x06proto3"
+
+var (
+	file_alcatraz_v2_ping_proto_rawDescOnce sync.Once
+	file_alcatraz_v2_ping_proto_rawDescData []byte
+)
+
+func file_alcatraz_v2_ping_proto_rawDescGZIP() []byte {
+	file_alcatraz_v2_ping_proto_rawDescOnce.Do(func() {
+		file_alcatraz_v2_ping_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_alcatraz_v2_ping_proto_rawDesc), len(file_alcatraz_v2_ping_proto_rawDesc)))
+	})
+	return file_alcatraz_v2_ping_proto_rawDescData
+}
+
+var file_alcatraz_v2_ping_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_alcatraz_v2_ping_proto_goTypes = []any{
+	(*PingRequest)(nil),           // 0: alcatraz.v2.PingRequest
+	(*PingResponse)(nil),          // 1: alcatraz.v2.PingResponse
+	(*RuntimeInfo)(nil),           // 2: alcatraz.v2.RuntimeInfo
+	(*ClientIdentity)(nil),        // 3: alcatraz.v2.ClientIdentity
+	(*NodeMetadata)(nil),          // 4: alcatraz.v2.NodeMetadata
+	nil,                           // 5: alcatraz.v2.NodeMetadata.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_alcatraz_v2_ping_proto_depIdxs = []int32{
+	6, // 0: alcatraz.v2.PingResponse.start_time:type_name -> google.protobuf.Timestamp
+	2, // 1: alcatraz.v2.PingResponse.runtime:type_name -> alcatraz.v2.RuntimeInfo
+	3, // 2: alcatraz.v2.PingResponse.client:type_name -> alcatraz.v2.ClientIdentity
+	4, // 3: alcatraz.v2.PingResponse.node:type_name -> alcatraz.v2.NodeMetadata
+	6, // 4: alcatraz.v2.ClientIdentity.not_after:type_name -> google.protobuf.Timestamp
+	5, // 5: alcatraz.v2.NodeMetadata.labels:type_name -> alcatraz.v2.NodeMetadata.LabelsEntry
+	0, // 6: alcatraz.v2.PingService.Ping:input_type -> alcatraz.v2.PingRequest
+	1, // 7: alcatraz.v2.PingService.Ping:output_type -> alcatraz.v2.PingResponse
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_alcatraz_v2_ping_proto_init() }
+func file_alcatraz_v2_ping_proto_init() {
+	if File_alcatraz_v2_ping_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_alcatraz_v2_ping_proto_rawDesc), len(file_alcatraz_v2_ping_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_alcatraz_v2_ping_proto_goTypes,
+		DependencyIndexes: file_alcatraz_v2_ping_proto_depIdxs,
+		MessageInfos:      file_alcatraz_v2_ping_proto_msgTypes,
+	}.Build()
+	File_alcatraz_v2_ping_proto = out.File
+	file_alcatraz_v2_ping_proto_goTypes = nil
+	file_alcatraz_v2_ping_proto_depIdxs = nil
+}
@@ -0,0 +1,145 @@
+// Package rpc serves the gRPC API of the node: the ping service generated
+// from proto/alcatraz/v2/ping.proto, the standard grpc.health.v1 service
+// and, when enabled, server reflection
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/rpc/pingpb"
+)
+
+// Server is the gRPC server of the node
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+// NewServer creates the gRPC server, it uses TLS with the configuration
+// of the REST API when tlsConfig is not nil and registers server
+// reflection when reflect is set
+func NewServer(node *apiv2.Node, tlsConfig *tls.Config, reflect bool) *Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := &Server{
+		grpc:   grpc.NewServer(opts...),
+		health: health.NewServer(),
+	}
+	pingpb.RegisterPingServiceServer(s.grpc, &pingServer{node: node})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	// Reflection lets tools such as grpcurl call the services without the
+	// protos, but also lists them to anyone who can connect
+	if reflect {
+		reflection.Register(s.grpc)
+	}
+
+	// The empty service name reports the health of the whole server
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(pingpb.PingService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Serve accepts connections on the listener until the server stops
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown reports every service as not serving and waits for the calls in
+// flight, they are cancelled when ctx ends first
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+// pingServer implements the ping service on top of the v2 node description
+type pingServer struct {
+	pingpb.UnimplementedPingServiceServer
+	node *apiv2.Node
+}
+
+func (s *pingServer) Ping(ctx context.Context, _ *pingpb.PingRequest) (*pingpb.PingResponse, error) {
+	var state *tls.ConnectionState
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+
+	response, err := s.node.Describe(state)
+	if err != nil {
+		slog.Error("failed to describe node", "error", err)
+		return nil, status.Error(codes.Internal, "the node could not be described")
+	}
+
+	slog.Info("Ping request handled", "api", "grpc", "hostname", response.Hostname, "remote_addr", remoteAddr)
+	return toProto(response), nil
+}
+
+// toProto converts the v2 ping response to its protobuf message
+func toProto(response apiv2.PingResponse) *pingpb.PingResponse {
+	message := &pingpb.PingResponse{
+		Message:       response.Message,
+		Hostname:      response.Hostname,
+		NodeId:        response.NodeID,
+		Version:       response.Version,
+		StartTime:     timestamppb.New(response.StartTime),
+		UptimeSeconds: response.UptimeSeconds,
+		Runtime: &pingpb.RuntimeInfo{
+			GoVersion:    response.Runtime.GoVersion,
+			Os:           response.Runtime.OS,
+			Arch:         response.Runtime.Arch,
+			NumCpu:       int32(response.Runtime.NumCPU),
+			NumGoroutine: int32(response.Runtime.NumGoroutine),
+		},
+		ListenAddress: response.ListenAddress,
+		TlsMode:       string(response.TLSMode),
+		Node: &pingpb.NodeMetadata{
+			Name:   response.Node.Name,
+			Zone:   response.Node.Zone,
+			Region: response.Node.Region,
+			Labels: response.Node.Labels,
+		},
+	}
+	if response.Client != nil {
+		message.Client = &pingpb.ClientIdentity{
+			Subject:      response.Client.Subject,
+			Issuer:       response.Client.Issuer,
+			SerialNumber: response.Client.SerialNumber,
+			NotAfter:     timestamppb.New(response.Client.NotAfter),
+		}
+	}
+	return message
+}
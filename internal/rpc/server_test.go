@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+	"github.com/ihatemodels/alcatraz-rest/internal/rpc/pingpb"
+)
+
+// dial starts the server on an in-memory listener and connects to it
+func dial(t *testing.T, server *Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		server.grpc.Stop()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+func TestServer_Ping(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := apiv2.NewNode("1.2.3", "0.0.0.0:8080", apiv2.TLSModeDisabled)
+	node.Metadata = apiv2.NodeMetadata{Name: "node-1", Zone: "eu-central-1a", Labels: apiv2.Labels{"team": "edge"}}
+	conn := dial(t, NewServer(node, nil, false))
+
+	resp, err := pingpb.NewPingServiceClient(conn).Ping(context.Background(), &pingpb.PingRequest{})
+	if err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	if resp.GetMessage() != "pong" || resp.GetHostname() != hostname || resp.GetNodeId() != node.ID {
+		t.Errorf("message, hostname, node_id = %q, %q, %q, want pong, %q, %q",
+			resp.GetMessage(), resp.GetHostname(), resp.GetNodeId(), hostname, node.ID)
+	}
+	if resp.GetVersion() != "1.2.3" || resp.GetTlsMode() != "disabled" || resp.GetListenAddress() != "0.0.0.0:8080" {
+		t.Errorf("version, tls_mode, listen_address = %q, %q, %q", resp.GetVersion(), resp.GetTlsMode(), resp.GetListenAddress())
+	}
+	if !resp.GetStartTime().AsTime().Equal(node.StartTime) {
+		t.Errorf("start_time = %v, want %v", resp.GetStartTime().AsTime(), node.StartTime)
+	}
+	if resp.GetRuntime().GetGoVersion() == "" || resp.GetRuntime().GetNumCpu() < 1 {
+		t.Errorf("runtime = %v, want the Go runtime", resp.GetRuntime())
+	}
+	if resp.GetClient() != nil {
+		t.Errorf("client = %v, want none for a plain connection", resp.GetClient())
+	}
+	if n := resp.GetNode(); n.GetName() != "node-1" || n.GetZone() != "eu-central-1a" || n.GetLabels()["team"] != "edge" {
+		t.Errorf("node = %v, want node-1 in eu-central-1a with team=edge", n)
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	server := NewServer(apiv2.NewNode("1.2.3", "0.0.0.0:8080", apiv2.TLSModeDisabled), nil, false)
+	health := healthpb.NewHealthClient(dial(t, server))
+
+	tests := []struct {
+		name       string
+		service    string
+		wantStatus healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{name: "server", service: "", wantStatus: healthpb.HealthCheckResponse_SERVING},
+		{name: "ping service", service: pingpb.PingService_ServiceDesc.ServiceName, wantStatus: healthpb.HealthCheckResponse_SERVING},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: tt.service})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if resp.GetStatus() != tt.wantStatus {
+				t.Errorf("status = %v, want %v", resp.GetStatus(), tt.wantStatus)
+			}
+		})
+	}
+
+	// Watchers learn that the server goes away before it stops
+	watch, err := health.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Recv() = %v, %v, want serving", resp, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		_ = server.Shutdown(ctx)
+	}()
+
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Recv() after shutdown = %v, %v, want not serving", resp, err)
+	}
+}
+
+func TestNewServer_Reflection(t *testing.T) {
+	tests := []struct {
+		name    string
+		reflect bool
+	}{
+		{name: "disabled", reflect: false},
+		{name: "enabled", reflect: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(apiv2.NewNode("1.2.3", "0.0.0.0:8080", apiv2.TLSModeDisabled), nil, tt.reflect)
+			_, registered := server.grpc.GetServiceInfo()["grpc.reflection.v1.ServerReflection"]
+			if registered != tt.reflect {
+				t.Errorf("reflection registered = %t, want %t", registered, tt.reflect)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package sender
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Protocols the sender can be forced to use
+const (
+	ProtocolAuto  = "auto"
+	ProtocolHTTP1 = "http1"
+	ProtocolHTTP2 = "http2"
+	ProtocolH2C   = "h2c"
+)
+
+// NewHTTPClient creates an HTTP client with the configured protocol and
+// connection reuse settings, Run uses it when no client is injected
+func NewHTTPClient(cfg *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, cfg.MaxIdleConnsPerHost)
+	}
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	protocols := new(http.Protocols)
+	switch cfg.Protocol {
+	case "", ProtocolAuto:
+		// HTTP/2 when the server offers it through TLS ALPN, HTTP/1.1 otherwise
+		protocols = nil
+	case ProtocolHTTP1:
+		protocols.SetHTTP1(true)
+	case ProtocolHTTP2:
+		// HTTP/2 over TLS only, requests to plain http:// URLs fail
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		// HTTP/2 with prior knowledge over plain TCP
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("invalid protocol: %s (must be %s, %s, %s or %s)",
+			cfg.Protocol, ProtocolAuto, ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C)
+	}
+	transport.Protocols = protocols
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+	}, nil
+}
+
+// TLSConfig returns the TLS configuration of the connections to the load
+// balancer, nil when the defaults apply
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if !c.InsecureSkipVerify && c.ClientCertFile == "" && c.ClientKeyFile == "" && c.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly requested for self-signed test certificates
+	}
+
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be set together")
+	}
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CAFile != "" {
+		caCert, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,83 @@
+package sender
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Config holds the configuration of a load balancer test
+type Config struct {
+	LoadBalancerURL string
+	RequestCount    int
+	Concurrency     int
+	Timeout         time.Duration
+
+	// Endpoints are requested in turn, the ping endpoint is used when empty
+	Endpoints []Endpoint
+	// NodeExtractor determines the serving node, the ping response is decoded when nil
+	NodeExtractor NodeExtractor
+	// Format is requested with the Accept header unless the endpoint sets one,
+	// the server default (JSON) is used when empty
+	Format string
+	// Rate limits the requests per second, 0 sends as fast as the workers allow
+	Rate int
+	// Duration stops the run after the given time, RequestCount 0 then means unlimited
+	Duration time.Duration
+	// GroupBy counts the requests per value of this node label as well
+	GroupBy string
+
+	// Connection model configuration, ignored when Client is set
+	Protocol            string
+	DisableKeepAlives   bool
+	MaxIdleConnsPerHost int
+	InsecureSkipVerify  bool
+	// ClientCertFile and ClientKeyFile are presented to servers that require
+	// a client certificate, CAFile verifies the server instead of the
+	// system roots
+	ClientCertFile string
+	ClientKeyFile  string
+	CAFile         string
+
+	// Retry configures how failed requests are retried
+	Retry RetryPolicy
+
+	// Client sends the requests, one is created with NewHTTPClient when nil
+	Client *http.Client
+	// Logger receives progress messages, nothing is logged when nil
+	Logger *slog.Logger
+	// Observers are notified about every completed request
+	Observers []Observer
+	// Reporters receive the result once the run is complete
+	Reporters []Reporter
+}
+
+// Endpoint describes a request sent to the load balancer
+type Endpoint struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// DefaultEndpoint is the ping endpoint of the application nodes
+var DefaultEndpoint = Endpoint{
+	Method: http.MethodGet,
+	Path:   "/api/ping",
+}
+
+// Endpoint returns the endpoint used for the given request number
+func (c *Config) Endpoint(reqNum int) Endpoint {
+	if len(c.Endpoints) == 0 {
+		return DefaultEndpoint
+	}
+	return c.Endpoints[(reqNum-1)%len(c.Endpoints)]
+}
+
+// logger returns the configured logger or one that discards everything
+func (c *Config) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return c.Logger
+}
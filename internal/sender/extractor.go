@@ -0,0 +1,204 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	apiv1 "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+	apiv2 "github.com/ihatemodels/alcatraz-rest/internal/api/v2"
+)
+
+// NodeExtractor determines which node served a response
+type NodeExtractor interface {
+	Extract(resp *http.Response) (string, error)
+}
+
+// pingExtractor reads the hostname from the ping endpoint response
+type pingExtractor struct{}
+
+// jsonPathExtractor reads the node from a field of a JSON response body,
+// path elements are object keys or array indexes
+type jsonPathExtractor struct {
+	path []string
+}
+
+// headerExtractor reads the node from a response header
+type headerExtractor struct {
+	name string
+}
+
+// nodeHeadersExtractor reads the node from the headers the nodes set on
+// every response, it needs no body and works for HEAD requests
+type nodeHeadersExtractor struct{}
+
+// ServedByHeader carries the node name unless the nodes are configured
+// with another header
+const ServedByHeader = "X-Served-By"
+
+// labelRecorder remembers the labels of the nodes found by the wrapped
+// extractor, the nodes send them in the X-Alcatraz-Node header
+type labelRecorder struct {
+	next NodeExtractor
+
+	mu     sync.Mutex
+	labels map[string]map[string]string
+}
+
+// ParseNodeExtractor creates an extractor from its command line form:
+// "ping" (the default), "headers", "json:<dotted.path>" or "header:<Name>"
+func ParseNodeExtractor(spec string) (NodeExtractor, error) {
+	if spec == "" || spec == "ping" {
+		return pingExtractor{}, nil
+	}
+	if spec == "headers" {
+		return nodeHeadersExtractor{}, nil
+	}
+
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid node extractor %q (must be ping, headers, json:<path> or header:<name>)", spec)
+	}
+
+	switch kind {
+	case "json":
+		return jsonPathExtractor{path: strings.Split(arg, ".")}, nil
+	case "header":
+		return headerExtractor{name: http.CanonicalHeaderKey(arg)}, nil
+	default:
+		return nil, fmt.Errorf("invalid node extractor %q (must be ping, headers, json:<path> or header:<name>)", spec)
+	}
+}
+
+// Extract decodes the ping response in the format of its Content-Type and
+// returns the hostname, responses of an unknown type are decoded as JSON
+func (pingExtractor) Extract(resp *http.Response) (string, error) {
+	format, ok := api.FormatByMediaType(resp.Header.Get("Content-Type"))
+	if !ok {
+		format = api.Formats[0]
+	}
+
+	if format.Name == "text" {
+		return extractPlainText(resp.Body)
+	}
+
+	var pingResp apiv1.PingResponse
+	if err := format.Decode(resp.Body, &pingResp); err != nil {
+		return "", fmt.Errorf("failed to decode %s response: %w", format.Name, err)
+	}
+
+	if pingResp.Hostname == "" {
+		return "", fmt.Errorf("response does not contain a hostname")
+	}
+
+	return pingResp.Hostname, nil
+}
+
+// extractPlainText reads the hostname from a "pong from <hostname>" response,
+// servers that do not set a Content-Type send JSON sniffed as plain text
+func extractPlainText(body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if hostname, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "pong from "); ok && hostname != "" {
+		return hostname, nil
+	}
+
+	var pingResp apiv1.PingResponse
+	if err := json.Unmarshal(data, &pingResp); err != nil || pingResp.Hostname == "" {
+		return "", fmt.Errorf("response does not contain a hostname")
+	}
+	return pingResp.Hostname, nil
+}
+
+// Extract walks the JSON body along the path and returns the value found there
+func (e jsonPathExtractor) Extract(resp *http.Response) (string, error) {
+	var value any
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for _, key := range e.path {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return "", fmt.Errorf("invalid index %q in JSON path %s", key, strings.Join(e.path, "."))
+			}
+			value = v[index]
+		default:
+			value = nil
+		}
+
+		if value == nil {
+			return "", fmt.Errorf("JSON path %s not found in response", strings.Join(e.path, "."))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("JSON path %s is empty", strings.Join(e.path, "."))
+		}
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("JSON path %s is not a scalar value", strings.Join(e.path, "."))
+	}
+}
+
+// Extract returns the value of the header, the node name for the
+// X-Alcatraz-Node header
+func (e headerExtractor) Extract(resp *http.Response) (string, error) {
+	value := resp.Header.Get(e.name)
+	if e.name == apiv2.NodeHeader {
+		value, _ = apiv2.ParseNodeHeader(value)
+	}
+	if value == "" {
+		return "", fmt.Errorf("response does not contain the %s header", e.name)
+	}
+
+	return value, nil
+}
+
+// Extract returns the node name of the X-Alcatraz-Node header, or the
+// X-Served-By header of nodes that only send that one
+func (nodeHeadersExtractor) Extract(resp *http.Response) (string, error) {
+	if name, _ := apiv2.ParseNodeHeader(resp.Header.Get(apiv2.NodeHeader)); name != "" {
+		return name, nil
+	}
+	if name := resp.Header.Get(ServedByHeader); name != "" {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("response does not contain the %s or %s header", apiv2.NodeHeader, ServedByHeader)
+}
+
+// Extract returns the node found by the wrapped extractor and records the
+// labels it sent
+func (r *labelRecorder) Extract(resp *http.Response) (string, error) {
+	hostname, err := r.next.Extract(resp)
+	value := resp.Header.Get(apiv2.NodeHeader)
+	if err != nil || value == "" {
+		return hostname, err
+	}
+
+	_, labels := apiv2.ParseNodeHeader(value)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.labels == nil {
+		r.labels = make(map[string]map[string]string)
+	}
+	r.labels[hostname] = labels
+	return hostname, nil
+}
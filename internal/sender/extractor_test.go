@@ -0,0 +1,170 @@
+package sender
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNodeExtractors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		body    string
+		header  http.Header
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "ping response",
+			spec: "ping",
+			body: `{"message":"pong","hostname":"node-a"}`,
+			want: "node-a",
+		},
+		{
+			name:   "plain text ping response",
+			spec:   "ping",
+			body:   "pong from node-a\n",
+			header: http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+			want:   "node-a",
+		},
+		{
+			name:   "xml ping response",
+			spec:   "ping",
+			body:   `<?xml version="1.0" encoding="UTF-8"?><PingResponse><message>pong</message><hostname>node-a</hostname></PingResponse>`,
+			header: http.Header{"Content-Type": []string{"application/xml"}},
+			want:   "node-a",
+		},
+		{
+			name:   "yaml ping response",
+			spec:   "ping",
+			body:   "message: pong\nhostname: node-a\n",
+			header: http.Header{"Content-Type": []string{"application/yaml"}},
+			want:   "node-a",
+		},
+		{
+			name:   "cbor ping response",
+			spec:   "ping",
+			body:   "\xa2\x67message\x64pong\x68hostname\x66node-a",
+			header: http.Header{"Content-Type": []string{"application/cbor"}},
+			want:   "node-a",
+		},
+		{
+			name:   "ping response with node header",
+			spec:   "ping",
+			body:   `{"message":"pong","hostname":"3f2a9c1b7d4e"}`,
+			header: http.Header{"X-Alcatraz-Node": []string{"node-a; zone=eu-central-1a"}},
+			want:   "3f2a9c1b7d4e",
+		},
+		{
+			name:    "ping response without hostname",
+			spec:    "",
+			body:    `{"message":"pong"}`,
+			wantErr: true,
+		},
+		{
+			name: "nested json path",
+			spec: "json:node.name",
+			body: `{"node":{"name":"node-b","zone":"a"}}`,
+			want: "node-b",
+		},
+		{
+			name: "json path with array index",
+			spec: "json:nodes.1",
+			body: `{"nodes":["node-a","node-c"]}`,
+			want: "node-c",
+		},
+		{
+			name: "numeric json value",
+			spec: "json:id",
+			body: `{"id":3}`,
+			want: "3",
+		},
+		{
+			name:    "missing json path",
+			spec:    "json:node.name",
+			body:    `{"node":{}}`,
+			wantErr: true,
+		},
+		{
+			name:    "json object value",
+			spec:    "json:node",
+			body:    `{"node":{"name":"node-b"}}`,
+			wantErr: true,
+		},
+		{
+			name:   "header",
+			spec:   "header:x-served-by",
+			header: http.Header{"X-Served-By": []string{"node-d"}},
+			want:   "node-d",
+		},
+		{
+			name:   "node header",
+			spec:   "header:X-Alcatraz-Node",
+			header: http.Header{"X-Alcatraz-Node": []string{"node-a; region=eu-central-1; zone=eu-central-1a"}},
+			want:   "node-a",
+		},
+		{
+			name:   "node headers",
+			spec:   "headers",
+			header: http.Header{"X-Alcatraz-Node": []string{"node-a; zone=a"}, "X-Served-By": []string{"node-b"}},
+			want:   "node-a",
+		},
+		{
+			name:   "served by header",
+			spec:   "headers",
+			header: http.Header{"X-Served-By": []string{"node-b"}},
+			want:   "node-b",
+		},
+		{
+			name:    "no node headers",
+			spec:    "headers",
+			body:    `{"message":"pong","hostname":"node-a"}`,
+			header:  http.Header{},
+			wantErr: true,
+		},
+		{
+			name:    "missing header",
+			spec:    "header:X-Served-By",
+			header:  http.Header{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor, err := ParseNodeExtractor(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseNodeExtractor(%q) error = %v", tt.spec, err)
+			}
+
+			resp := &http.Response{
+				Header: tt.header,
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			got, err := extractor.Extract(resp)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Extract() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNodeExtractor_Invalid(t *testing.T) {
+	for _, spec := range []string{"json:", "header", "xpath://node"} {
+		if _, err := ParseNodeExtractor(spec); err == nil {
+			t.Errorf("ParseNodeExtractor(%q) error = nil, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sender
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// histogramPrecisionBits is the number of significant bits kept per bucket.
+// Values below 2^histogramPrecisionBits are stored exactly and larger values
+// with a relative error below 0.2%.
+const histogramPrecisionBits = 10
+
+// LatencyHistogram records response times in milliseconds using log-linear
+// buckets, so memory stays bounded no matter how many values are recorded
+type LatencyHistogram struct {
+	Count   int64         `json:"count"`
+	Sum     int64         `json:"sum"`
+	Min     int64         `json:"min"`
+	Max     int64         `json:"max"`
+	Buckets map[int]int64 `json:"buckets"`
+}
+
+// NewLatencyHistogram creates an empty histogram
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Buckets: make(map[int]int64),
+	}
+}
+
+// Record adds a single value, negative values are recorded as zero
+func (h *LatencyHistogram) Record(value int64) {
+	value = max(value, 0)
+
+	if h.Count == 0 || value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+
+	h.Count++
+	h.Sum += value
+	h.Buckets[bucketIndex(value)]++
+}
+
+// Merge adds all values recorded by other to the histogram
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+
+	h.Count += other.Count
+	h.Sum += other.Sum
+	for index, count := range other.Buckets {
+		h.Buckets[index] += count
+	}
+}
+
+// Mean returns the average of the recorded values
+func (h *LatencyHistogram) Mean() int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / h.Count
+}
+
+// Quantile returns the value below which the p-th percentile of the recorded
+// values fall, using the nearest-rank method on bucket lower bounds
+func (h *LatencyHistogram) Quantile(p float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.Count)))
+	rank = min(max(rank, 1), h.Count)
+	if rank == h.Count {
+		return h.Max
+	}
+
+	indexes := make([]int, 0, len(h.Buckets))
+	for index := range h.Buckets {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var seen int64
+	for _, index := range indexes {
+		seen += h.Buckets[index]
+		if seen >= rank {
+			// Clamp so that the estimate never falls outside the exact range
+			return min(max(bucketLowerBound(index), h.Min), h.Max)
+		}
+	}
+
+	return h.Max
+}
+
+// bucketIndex maps a value to its bucket
+func bucketIndex(value int64) int {
+	const exact = 1 << histogramPrecisionBits
+	if value < exact {
+		return int(value)
+	}
+
+	// Keep the top histogramPrecisionBits bits of the value
+	shift := bits.Len64(uint64(value)) - histogramPrecisionBits
+	mantissa := int(value >> shift)
+
+	return exact + (shift-1)*(exact/2) + (mantissa - exact/2)
+}
+
+// bucketLowerBound returns the smallest value that maps to the bucket
+func bucketLowerBound(index int) int64 {
+	const exact = 1 << histogramPrecisionBits
+	if index < exact {
+		return int64(index)
+	}
+
+	offset := index - exact
+	shift := offset/(exact/2) + 1
+	mantissa := offset%(exact/2) + exact/2
+
+	return int64(mantissa) << shift
+}
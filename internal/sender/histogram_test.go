@@ -0,0 +1,126 @@
+package sender
+
+import (
+	"testing"
+)
+
+func TestLatencyHistogram_Quantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		p      float64
+		want   int64
+	}{
+		{
+			name: "empty histogram",
+			p:    99,
+			want: 0,
+		},
+		{
+			name:   "single value",
+			values: []int64{42},
+			p:      50,
+			want:   42,
+		},
+		{
+			name:   "exact range median",
+			values: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			p:      50,
+			want:   5,
+		},
+		{
+			name:   "exact range p99",
+			values: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 100},
+			p:      99,
+			want:   100,
+		},
+		{
+			name:   "clamped to max",
+			values: []int64{5000, 5001, 5003},
+			p:      100,
+			want:   5003,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewLatencyHistogram()
+			for _, v := range tt.values {
+				h.Record(v)
+			}
+
+			if got := h.Quantile(tt.p); got != tt.want {
+				t.Errorf("Quantile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyHistogram_RelativeError(t *testing.T) {
+	for _, v := range []int64{1023, 1024, 1500, 65_537, 3_600_000} {
+		lower := bucketLowerBound(bucketIndex(v))
+		if lower > v {
+			t.Errorf("bucketLowerBound(bucketIndex(%d)) = %d, want <= %d", v, lower, v)
+		}
+		if float64(v-lower)/float64(v) > 0.002 {
+			t.Errorf("bucketLowerBound(bucketIndex(%d)) = %d, relative error too large", v, lower)
+		}
+	}
+}
+
+func TestLatencyHistogram_Merge(t *testing.T) {
+	a := NewLatencyHistogram()
+	b := NewLatencyHistogram()
+	for i := int64(1); i <= 50; i++ {
+		a.Record(i)
+		b.Record(i + 50)
+	}
+
+	a.Merge(b)
+
+	if a.Count != 100 {
+		t.Errorf("Count = %d, want 100", a.Count)
+	}
+	if a.Min != 1 || a.Max != 100 {
+		t.Errorf("Min, Max = %d, %d, want 1, 100", a.Min, a.Max)
+	}
+	if a.Mean() != 50 {
+		t.Errorf("Mean() = %d, want 50", a.Mean())
+	}
+	if got := a.Quantile(90); got != 90 {
+		t.Errorf("Quantile(90) = %d, want 90", got)
+	}
+}
+
+func TestLoadBalancerStats_Merge(t *testing.T) {
+	workers := []*LoadBalancerStats{NewLoadBalancerStats(), NewLoadBalancerStats()}
+	workers[0].Record("node-a", "HTTP/1.1", 10, nil)
+	workers[0].Record("", "HTTP/1.1", 0, errTest)
+	workers[1].Record("node-a", "HTTP/1.1", 20, nil)
+	workers[1].Record("node-b", "HTTP/1.1", 30, nil)
+
+	stats := NewLoadBalancerStats()
+	for _, w := range workers {
+		stats.Merge(w)
+	}
+	stats.Finalize()
+
+	if stats.TotalRequests != 4 || stats.SuccessfulReqs != 3 || stats.FailedRequests != 1 {
+		t.Errorf("totals = %d/%d/%d, want 4/3/1", stats.TotalRequests, stats.SuccessfulReqs, stats.FailedRequests)
+	}
+	if stats.RequestsPerNode["node-a"] != 2 || stats.RequestsPerNode["node-b"] != 1 {
+		t.Errorf("RequestsPerNode = %v, want node-a: 2, node-b: 1", stats.RequestsPerNode)
+	}
+	if stats.AvailableNodes != 2 {
+		t.Errorf("AvailableNodes = %d, want 2", stats.AvailableNodes)
+	}
+	if stats.AverageRespTime != 20 {
+		t.Errorf("AverageRespTime = %d, want 20", stats.AverageRespTime)
+	}
+}
+
+var errTest = testError("connection refused")
+
+type testError string
+
+func (e testError) Error() string { return string(e) }
@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result is the outcome of a completed run
+type Result struct {
+	Stats    *LoadBalancerStats
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Reporter receives the result of a run
+type Reporter interface {
+	Report(result *Result) error
+}
+
+// Observer is notified about every completed request, it must be safe for
+// concurrent use as the workers call it in parallel
+type Observer interface {
+	Observe(entry TimelineEntry)
+}
+
+// TimelineEntry records the outcome of a single request
+type TimelineEntry struct {
+	Request  int       `json:"request"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Hostname string    `json:"hostname,omitempty"`
+	Protocol string    `json:"protocol,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// TextReporter writes the statistics in a human readable form
+type TextReporter struct {
+	W io.Writer
+	// Title is written as a heading above the statistics when set
+	Title string
+}
+
+// Report writes the statistics of the result
+func (r TextReporter) Report(result *Result) error {
+	if r.Title != "" {
+		fmt.Fprintf(r.W, "\n=== %s ===\n", r.Title)
+	}
+	WriteStats(r.W, result.Stats)
+	return nil
+}
+
+// JSONReporter writes the summary statistics as indented JSON
+type JSONReporter struct {
+	W io.Writer
+	// Title is written as a heading above the JSON document when set
+	Title string
+}
+
+// Report writes the summary of the result
+func (r JSONReporter) Report(result *Result) error {
+	jsonOutput, err := json.MarshalIndent(result.Stats.Summary(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats to JSON: %w", err)
+	}
+
+	if r.Title != "" {
+		fmt.Fprintf(r.W, "\n=== %s ===\n", r.Title)
+	}
+	fmt.Fprintln(r.W, string(jsonOutput))
+	return nil
+}
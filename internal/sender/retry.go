@@ -0,0 +1,261 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error classes that can be retried besides the status codes
+const (
+	RetryErrorTimeout    = "timeout"
+	RetryErrorConnection = "connection"
+)
+
+// UnknownNode stands for the node of a failed request that could not be identified
+const UnknownNode = "unknown"
+
+// RetryPolicy configures how failed requests are retried
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is doubled for every retry up to MaxDelay, the actual
+	// delay is drawn uniformly from zero to that value
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryOn lists the status codes that are retried
+	RetryOn []int
+	// RetryErrors lists the error classes that are retried
+	RetryErrors []string
+}
+
+// Enabled reports whether failed requests are retried at all
+func (p RetryPolicy) Enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// retryable reports whether err is worth another attempt
+func (p RetryPolicy) retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(p.RetryOn, statusErr.Code)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		// Failures to identify the node are not transient
+		return false
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return slices.Contains(p.RetryErrors, RetryErrorTimeout)
+	}
+
+	return slices.Contains(p.RetryErrors, RetryErrorConnection)
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.MaxDelay
+	// Stop doubling before the shift overflows
+	if retry <= 32 && p.BaseDelay<<(retry-1) < limit {
+		limit = p.BaseDelay << (retry - 1)
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit + 1)
+}
+
+// ParseRetryPolicy builds the policy from the comma separated flag values
+func ParseRetryPolicy(attempts int, baseDelay, maxDelay time.Duration, retryOn, retryErrors string) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		MaxAttempts: attempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+	}
+
+	if attempts < 1 {
+		return policy, fmt.Errorf("invalid retry attempts: %d (must be at least 1)", attempts)
+	}
+	if baseDelay < 0 || maxDelay < baseDelay {
+		return policy, fmt.Errorf("invalid retry backoff: %s up to %s", baseDelay, maxDelay)
+	}
+
+	for _, value := range strings.Split(retryOn, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 100 || code > 599 {
+			return policy, fmt.Errorf("invalid retry status code %q", value)
+		}
+		policy.RetryOn = append(policy.RetryOn, code)
+	}
+
+	for _, value := range strings.Split(retryErrors, ",") {
+		value = strings.TrimSpace(value)
+		switch value {
+		case "":
+		case RetryErrorTimeout, RetryErrorConnection:
+			policy.RetryErrors = append(policy.RetryErrors, value)
+		default:
+			return policy, fmt.Errorf("invalid retry error class %q (must be %s or %s)", value, RetryErrorTimeout, RetryErrorConnection)
+		}
+	}
+
+	return policy, nil
+}
+
+// statusError is returned for responses outside the 2xx range
+type statusError struct {
+	Code int
+	// Hostname is the node that sent the response, when it could be identified
+	Hostname string
+	// Detail explains the failure when the response is a problem
+	Detail string
+}
+
+// FailedNode returns the node that answered a failed request, UnknownNode
+// when the request got no response or the node could not be identified
+func FailedNode(err error) string {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.Hostname != "" {
+		return statusErr.Hostname
+	}
+	return UnknownNode
+}
+
+func (e *statusError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("unexpected status code: %d (%s)", e.Code, e.Detail)
+	}
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// RetryOutcome describes the attempts made for a single request
+type RetryOutcome struct {
+	Attempts int
+	// FailedNodes holds the node of every failed attempt
+	FailedNodes []string
+}
+
+// SendWithRetry sends the request and retries it according to cfg.Retry
+func SendWithRetry(ctx context.Context, client *http.Client, cfg *Config, endpoint Endpoint) (string, string, RetryOutcome, error) {
+	var outcome RetryOutcome
+
+	for {
+		outcome.Attempts++
+		hostname, proto, err := Send(ctx, client, cfg, endpoint)
+		if err == nil {
+			return hostname, proto, outcome, nil
+		}
+
+		outcome.FailedNodes = append(outcome.FailedNodes, FailedNode(err))
+
+		if outcome.Attempts >= cfg.Retry.MaxAttempts || !cfg.Retry.retryable(err) || ctx.Err() != nil {
+			return hostname, proto, outcome, err
+		}
+
+		timer := time.NewTimer(cfg.Retry.backoff(outcome.Attempts))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return hostname, proto, outcome, err
+		}
+	}
+}
+
+// RetryStats holds the outcome of retried requests
+type RetryStats struct {
+	FirstAttemptSuccess int `json:"first_attempt_success"`
+	SuccessAfterRetry   int `json:"success_after_retry"`
+	RetriesExhausted    int `json:"retries_exhausted"`
+	NotRetried          int `json:"not_retried"`
+	Retries             int `json:"retries"`
+	// FailedAttemptsPerNode counts failed attempts by the node that served them
+	FailedAttemptsPerNode map[string]int `json:"failed_attempts_per_node"`
+	// RecoveredPerNode counts successful retries by the node that served them
+	RecoveredPerNode map[string]int `json:"recovered_per_node"`
+}
+
+func newRetryStats() *RetryStats {
+	return &RetryStats{
+		FailedAttemptsPerNode: make(map[string]int),
+		RecoveredPerNode:      make(map[string]int),
+	}
+}
+
+// record adds the outcome of a single request
+func (r *RetryStats) record(outcome RetryOutcome, hostname string, maxAttempts int, err error) {
+	r.Retries += outcome.Attempts - 1
+	for _, node := range outcome.FailedNodes {
+		r.FailedAttemptsPerNode[node]++
+	}
+
+	switch {
+	case err == nil && outcome.Attempts == 1:
+		r.FirstAttemptSuccess++
+	case err == nil:
+		r.SuccessAfterRetry++
+		r.RecoveredPerNode[hostname]++
+	case outcome.Attempts >= maxAttempts:
+		r.RetriesExhausted++
+	default:
+		r.NotRetried++
+	}
+}
+
+func (r *RetryStats) merge(other *RetryStats) {
+	r.FirstAttemptSuccess += other.FirstAttemptSuccess
+	r.SuccessAfterRetry += other.SuccessAfterRetry
+	r.RetriesExhausted += other.RetriesExhausted
+	r.NotRetried += other.NotRetried
+	r.Retries += other.Retries
+
+	for node, count := range other.FailedAttemptsPerNode {
+		r.FailedAttemptsPerNode[node] += count
+	}
+	for node, count := range other.RecoveredPerNode {
+		r.RecoveredPerNode[node] += count
+	}
+}
+
+// writeRetries writes the retry statistics
+func writeRetries(w io.Writer, r *RetryStats) {
+	fmt.Fprintln(w, "\n=== Retries ===")
+	fmt.Fprintf(w, "First Attempt Success: %d\n", r.FirstAttemptSuccess)
+	fmt.Fprintf(w, "Success After Retry: %d\n", r.SuccessAfterRetry)
+	fmt.Fprintf(w, "Retries Exhausted: %d\n", r.RetriesExhausted)
+	fmt.Fprintf(w, "Failed Without Retry: %d\n", r.NotRetried)
+	fmt.Fprintf(w, "Total Retries: %d\n", r.Retries)
+
+	nodes := make([]string, 0, len(r.FailedAttemptsPerNode)+len(r.RecoveredPerNode))
+	for node := range r.FailedAttemptsPerNode {
+		nodes = append(nodes, node)
+	}
+	for node := range r.RecoveredPerNode {
+		if _, ok := r.FailedAttemptsPerNode[node]; !ok {
+			nodes = append(nodes, node)
+		}
+	}
+	slices.Sort(nodes)
+
+	for _, node := range nodes {
+		fmt.Fprintf(w, "%-20s: failed attempts=%d, recovered=%d\n",
+			node, r.FailedAttemptsPerNode[node], r.RecoveredPerNode[node])
+	}
+}
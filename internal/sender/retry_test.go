@@ -0,0 +1,146 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+	apiv1 "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+)
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	policy, err := ParseRetryPolicy(3, time.Millisecond, time.Second, "503", RetryErrorConnection)
+	if err != nil {
+		t.Fatalf("ParseRetryPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "listed status code", err: &statusError{Code: 503}, want: true},
+		{name: "other status code", err: &statusError{Code: 500}, want: false},
+		{name: "connection error", err: &url.Error{Op: "Get", Err: errTest}, want: true},
+		{name: "timeout", err: &url.Error{Op: "Get", Err: context.DeadlineExceeded}, want: false},
+		{name: "node extraction error", err: errTest, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	for retry, limit := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 100: 50} {
+		for range 100 {
+			if got := policy.backoff(retry); got < 0 || got > limit*time.Millisecond {
+				t.Fatalf("backoff(%d) = %v, want between 0 and %v", retry, got, limit*time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestParseRetryPolicy_Invalid(t *testing.T) {
+	if _, err := ParseRetryPolicy(0, 0, 0, "", ""); err == nil {
+		t.Error("ParseRetryPolicy() with zero attempts error = nil, want error")
+	}
+	if _, err := ParseRetryPolicy(2, 0, time.Second, "50x", ""); err == nil {
+		t.Error("ParseRetryPolicy() with invalid status code error = nil, want error")
+	}
+	if _, err := ParseRetryPolicy(2, 0, time.Second, "", "dns"); err == nil {
+		t.Error("ParseRetryPolicy() with unknown error class error = nil, want error")
+	}
+}
+
+func TestSendWithRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("X-Node", "node-a")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Node", "node-b")
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		LoadBalancerURL: server.URL,
+		NodeExtractor:   headerExtractor{name: "X-Node"},
+		Retry:           RetryPolicy{MaxAttempts: 3, RetryOn: []int{http.StatusServiceUnavailable}},
+	}
+
+	hostname, _, outcome, err := SendWithRetry(context.Background(), server.Client(), cfg, DefaultEndpoint)
+	if err != nil {
+		t.Fatalf("SendWithRetry() error = %v", err)
+	}
+	if hostname != "node-b" || outcome.Attempts != 2 {
+		t.Errorf("SendWithRetry() = %s after %d attempts, want node-b after 2", hostname, outcome.Attempts)
+	}
+
+	stats := newRetryStats()
+	stats.record(outcome, hostname, cfg.Retry.MaxAttempts, err)
+	if stats.SuccessAfterRetry != 1 || stats.FailedAttemptsPerNode["node-a"] != 1 || stats.RecoveredPerNode["node-b"] != 1 {
+		t.Errorf("RetryStats = %+v, want one recovery from node-a to node-b", stats)
+	}
+}
+
+func TestSendWithRetry_ProblemFromNamedNode(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("X-Alcatraz-Node", "node-a; zone=a")
+			api.WriteProblem(w, r, http.StatusBadGateway, "injected fault")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(apiv1.PingResponse{Message: "pong", Hostname: "node-b"})
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		LoadBalancerURL: server.URL,
+		Retry:           RetryPolicy{MaxAttempts: 2, RetryOn: []int{http.StatusBadGateway}},
+	}
+
+	hostname, _, outcome, err := SendWithRetry(context.Background(), server.Client(), cfg, DefaultEndpoint)
+	if err != nil {
+		t.Fatalf("SendWithRetry() error = %v", err)
+	}
+
+	stats := newRetryStats()
+	stats.record(outcome, hostname, cfg.Retry.MaxAttempts, err)
+	if stats.FailedAttemptsPerNode["node-a"] != 1 || stats.FailedAttemptsPerNode[UnknownNode] != 0 {
+		t.Errorf("FailedAttemptsPerNode = %v, want the problem counted against node-a", stats.FailedAttemptsPerNode)
+	}
+}
+
+func TestSend_ProblemDetailAndNode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "node-c")
+		api.WriteProblem(w, r, http.StatusServiceUnavailable, "node draining")
+	}))
+	defer server.Close()
+
+	_, _, err := Send(context.Background(), server.Client(), &Config{LoadBalancerURL: server.URL}, DefaultEndpoint)
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Send() error = %v, want a status error", err)
+	}
+	if statusErr.Hostname != "node-c" || statusErr.Detail == "" {
+		t.Errorf("statusError = %+v, want node-c with the problem detail", statusErr)
+	}
+}
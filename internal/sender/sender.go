@@ -0,0 +1,300 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihatemodels/alcatraz-rest/internal/api"
+)
+
+// maxErrorBodySize limits how much of an error response is read
+const maxErrorBodySize = 64 << 10
+
+// Run sends the configured requests from a fixed pool of cfg.Concurrency
+// workers, collects statistics and hands the result to cfg.Reporters. Each
+// worker aggregates into its own LoadBalancerStats, which are merged once all
+// workers are done. When ctx is cancelled no new requests are started,
+// requests in flight are aborted and left out of the statistics, and the
+// partial results are returned marked as interrupted.
+func Run(ctx context.Context, cfg *Config) (*Result, error) {
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency: %d (must be at least 1)", cfg.Concurrency)
+	}
+
+	if cfg.RequestCount < 1 && cfg.Duration <= 0 {
+		return nil, fmt.Errorf("either a request count or a duration is required")
+	}
+
+	client := cfg.Client
+	if client == nil {
+		var err error
+		if client, err = NewHTTPClient(cfg); err != nil {
+			return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+		}
+	}
+
+	logger := cfg.logger()
+
+	// Remember the labels of the nodes for grouping the statistics
+	labels := &labelRecorder{next: cfg.NodeExtractor}
+	if labels.next == nil {
+		labels.next = pingExtractor{}
+	}
+	runCfg := *cfg
+	runCfg.NodeExtractor = labels
+
+	logger.Info("starting load balancer test",
+		"url", cfg.LoadBalancerURL,
+		"requests", cfg.RequestCount,
+		"concurrency", cfg.Concurrency,
+		"rate", cfg.Rate,
+		"duration", cfg.Duration,
+		"protocol", cfg.Protocol,
+		"keepalive", !cfg.DisableKeepAlives,
+		"max_attempts", cfg.Retry.MaxAttempts)
+
+	startTime := time.Now()
+
+	requests := generateRequests(ctx, cfg.RequestCount, cfg.Rate, cfg.Duration)
+	workerStats := make([]*LoadBalancerStats, cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := range workerStats {
+		workerStats[i] = NewLoadBalancerStats()
+
+		wg.Add(1)
+		go func(stats *LoadBalancerStats) {
+			defer wg.Done()
+
+			for reqNum := range requests {
+				reqStart := time.Now()
+				hostname, proto, outcome, err := SendWithRetry(ctx, client, &runCfg, cfg.Endpoint(reqNum))
+				reqEnd := time.Now()
+				reqDuration := reqEnd.Sub(reqStart).Milliseconds()
+
+				// Requests aborted by the interruption say nothing about the nodes
+				if ctx.Err() != nil {
+					return
+				}
+
+				entry := TimelineEntry{Request: reqNum, Start: reqStart, End: reqEnd, Hostname: hostname, Protocol: proto}
+				if err != nil {
+					entry.Error = err.Error()
+				}
+				for _, o := range cfg.Observers {
+					o.Observe(entry)
+				}
+
+				stats.Record(hostname, proto, reqDuration, err)
+				if cfg.Retry.Enabled() {
+					stats.RecordRetry(outcome, hostname, cfg.Retry.MaxAttempts, err)
+				}
+
+				if err != nil {
+					logger.Debug("request failed", "request", reqNum, "error", err)
+					continue
+				}
+
+				logger.Debug("request completed",
+					"request", reqNum,
+					"hostname", hostname,
+					"response_time_ms", reqDuration)
+			}
+		}(workerStats[i])
+	}
+
+	wg.Wait()
+	totalDuration := time.Since(startTime)
+
+	stats := NewLoadBalancerStats()
+	for _, ws := range workerStats {
+		stats.Merge(ws)
+	}
+	for hostname, nodeLabels := range labels.labels {
+		stats.RecordLabels(hostname, nodeLabels)
+	}
+
+	if ctx.Err() != nil {
+		stats.Interrupted = true
+		logger.Warn("load balancer test interrupted, reporting partial results",
+			"completed_requests", stats.TotalRequests,
+			"planned_requests", cfg.RequestCount)
+	}
+
+	// Calculate final statistics
+	stats.Finalize()
+	if cfg.GroupBy != "" {
+		stats.Group(cfg.GroupBy)
+	}
+
+	logger.Info("load balancer test completed",
+		"total_duration", totalDuration,
+		"total_requests", stats.TotalRequests,
+		"successful_requests", stats.SuccessfulReqs,
+		"failed_requests", stats.FailedRequests,
+		"available_nodes", stats.AvailableNodes)
+
+	result := &Result{
+		Stats:    stats,
+		Start:    startTime,
+		Duration: totalDuration,
+	}
+
+	for _, r := range cfg.Reporters {
+		if err := r.Report(result); err != nil {
+			return result, fmt.Errorf("failed to report results: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// generateRequests feeds request numbers starting at 1 to the workers, at
+// most rate per second when rate is positive. It stops after count requests
+// (unless count is 0), once duration has passed (unless duration is 0) or when
+// ctx is cancelled, and closes the channel.
+func generateRequests(ctx context.Context, count, rate int, duration time.Duration) <-chan int {
+	requests := make(chan int)
+
+	go func() {
+		defer close(requests)
+
+		var deadline <-chan time.Time
+		if duration > 0 {
+			timer := time.NewTimer(duration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		var tick <-chan time.Time
+		if rate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i := 1; count < 1 || i <= count; i++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-deadline:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case requests <- i:
+			case <-deadline:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return requests
+}
+
+// Send sends a single request for the endpoint to the load balancer and
+// returns the hostname of the node that served it and the negotiated protocol
+func Send(ctx context.Context, client *http.Client, cfg *Config, endpoint Endpoint) (string, string, error) {
+	var body io.Reader
+	if endpoint.Body != "" {
+		body = strings.NewReader(endpoint.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, endpoint.Method, cfg.LoadBalancerURL+endpoint.Path, body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for name, value := range endpoint.Headers {
+		// The Host header is taken from the request, not from its headers
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	if cfg.Format != "" && req.Header.Get("Accept") == "" {
+		format, ok := api.FormatByName(cfg.Format)
+		if !ok {
+			return "", "", fmt.Errorf("unknown response format %q", cfg.Format)
+		}
+		req.Header.Set("Accept", format.MediaType)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	extractor := cfg.NodeExtractor
+	if extractor == nil {
+		extractor = pingExtractor{}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", newStatusError(resp, extractor)
+	}
+
+	hostname, err := extractor.Extract(resp)
+	return hostname, resp.Proto, err
+}
+
+// newStatusError describes an error response, the body is buffered so both
+// the problem and the node can be read from it
+func newStatusError(resp *http.Response, extractor NodeExtractor) *statusError {
+	statusErr := &statusError{Code: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if problem, ok := decodeProblem(resp); ok {
+		statusErr.Detail = problemDetail(problem)
+	}
+
+	// Best effort, error responses rarely carry the node in the body but
+	// the nodes identify themselves in the headers of every response
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if hostname, err := extractor.Extract(resp); err == nil {
+		statusErr.Hostname = hostname
+	} else if hostname, err := (nodeHeadersExtractor{}).Extract(resp); err == nil {
+		statusErr.Hostname = hostname
+	}
+	return statusErr
+}
+
+// decodeProblem decodes the problem details of an error response, it
+// reports false when the response is not a problem
+func decodeProblem(resp *http.Response) (api.Problem, bool) {
+	var problem api.Problem
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != api.ProblemContentType {
+		return problem, false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		return problem, false
+	}
+	return problem, true
+}
+
+// problemDetail summarises a problem in a single line
+func problemDetail(problem api.Problem) string {
+	if problem.Detail == "" {
+		return problem.Title
+	}
+	return problem.Title + ": " + problem.Detail
+}
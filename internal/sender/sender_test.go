@@ -0,0 +1,192 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "github.com/ihatemodels/alcatraz-rest/internal/api/v1"
+)
+
+// reporterFunc adapts a function to the Reporter interface
+type reporterFunc func(result *Result) error
+
+func (f reporterFunc) Report(result *Result) error { return f(result) }
+
+// observerFunc adapts a function to the Observer interface
+type observerFunc func(entry TimelineEntry)
+
+func (f observerFunc) Observe(entry TimelineEntry) { f(entry) }
+
+func TestRun(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := "node-a"
+		if requests.Add(1)%2 == 0 {
+			hostname = "node-b"
+		}
+		_ = json.NewEncoder(w).Encode(api.PingResponse{Message: "pong", Hostname: hostname})
+	}))
+	defer server.Close()
+
+	var observed atomic.Int32
+	var reported *Result
+	cfg := &Config{
+		LoadBalancerURL: server.URL,
+		RequestCount:    20,
+		Concurrency:     4,
+		Client:          server.Client(),
+		Observers: []Observer{observerFunc(func(TimelineEntry) {
+			observed.Add(1)
+		})},
+		Reporters: []Reporter{reporterFunc(func(result *Result) error {
+			reported = result
+			return nil
+		})},
+	}
+
+	result, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Stats.TotalRequests != 20 || result.Stats.SuccessfulReqs != 20 {
+		t.Errorf("TotalRequests, SuccessfulReqs = %d, %d, want 20, 20", result.Stats.TotalRequests, result.Stats.SuccessfulReqs)
+	}
+	if result.Stats.RequestsPerNode["node-a"] != 10 || result.Stats.RequestsPerNode["node-b"] != 10 {
+		t.Errorf("RequestsPerNode = %v, want node-a: 10, node-b: 10", result.Stats.RequestsPerNode)
+	}
+	if observed.Load() != 20 {
+		t.Errorf("observed %d requests, want 20", observed.Load())
+	}
+	if reported != result {
+		t.Error("reporter did not receive the result")
+	}
+}
+
+func TestRun_GroupBy(t *testing.T) {
+	zones := map[string]string{"node-a": "zone=a", "node-b": "zone=a", "node-c": "team=edge"}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := []string{"node-a", "node-b", "node-c"}[requests.Add(1)%3]
+		w.Header().Set("X-Alcatraz-Node", hostname+"; "+zones[hostname])
+		_ = json.NewEncoder(w).Encode(api.PingResponse{Message: "pong", Hostname: hostname})
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), &Config{
+		LoadBalancerURL: server.URL,
+		RequestCount:    30,
+		Concurrency:     3,
+		GroupBy:         "zone",
+		Client:          server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := result.Stats.RequestsPerGroup; len(got) != 2 || got["a"] != 20 || got[UnlabeledGroup] != 10 {
+		t.Errorf("RequestsPerGroup = %v, want a: 20, %s: 10", got, UnlabeledGroup)
+	}
+	if got := result.Stats.NodeLabels["node-c"]["team"]; got != "edge" {
+		t.Errorf("NodeLabels[node-c][team] = %q, want edge", got)
+	}
+}
+
+func TestRun_Interrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.PingResponse{Message: "pong", Hostname: "node-a"})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := Run(ctx, &Config{
+		LoadBalancerURL: server.URL,
+		Concurrency:     2,
+		Rate:            10,
+		Duration:        time.Minute,
+		Client:          server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Stats.Interrupted {
+		t.Error("Interrupted = false, want true")
+	}
+}
+
+func TestRun_InterruptedMidRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.PingResponse{Message: "pong", Hostname: "node-a"})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var observed atomic.Int32
+	var reported *Result
+	result, err := Run(ctx, &Config{
+		LoadBalancerURL: server.URL,
+		RequestCount:    1000,
+		Concurrency:     2,
+		Rate:            200,
+		Client:          server.Client(),
+		Observers: []Observer{observerFunc(func(TimelineEntry) {
+			if observed.Add(1) == 10 {
+				cancel()
+			}
+		})},
+		Reporters: []Reporter{reporterFunc(func(result *Result) error {
+			reported = result
+			return nil
+		})},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if reported != result {
+		t.Fatal("reporter did not receive the partial result")
+	}
+	if !result.Stats.Interrupted {
+		t.Error("Interrupted = false, want true")
+	}
+	if got := result.Stats.TotalRequests; got < 10 || got >= 1000 {
+		t.Errorf("TotalRequests = %d, want the requests completed before the interruption", got)
+	}
+	if got := result.Stats.RequestsPerNode["node-a"]; got != result.Stats.SuccessfulReqs {
+		t.Errorf("RequestsPerNode[node-a] = %d, want %d", got, result.Stats.SuccessfulReqs)
+	}
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	if _, err := Run(context.Background(), &Config{Concurrency: 1}); err == nil {
+		t.Error("Run() without request count or duration error = nil, want error")
+	}
+	if _, err := Run(context.Background(), &Config{RequestCount: 1}); err == nil {
+		t.Error("Run() without concurrency error = nil, want error")
+	}
+}
+
+func TestSend_Problem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"the node accepts 10 requests per second"}`))
+	}))
+	defer server.Close()
+
+	_, _, err := Send(context.Background(), server.Client(), &Config{LoadBalancerURL: server.URL}, DefaultEndpoint)
+
+	want := "unexpected status code: 429 (Too Many Requests: the node accepts 10 requests per second)"
+	if err == nil || err.Error() != want {
+		t.Errorf("Send() error = %v, want %q", err, want)
+	}
+}
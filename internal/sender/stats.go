@@ -0,0 +1,272 @@
+package sender
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LoadBalancerStats holds statistics about load balancer distribution
+type LoadBalancerStats struct {
+	AvailableNodes   int                          `json:"available_nodes"`
+	TotalRequests    int                          `json:"total_requests"`
+	SuccessfulReqs   int                          `json:"successful_requests"`
+	FailedRequests   int                          `json:"failed_requests"`
+	AverageRespTime  int64                        `json:"average_response_time_ms"`
+	NodeHostnames    []string                     `json:"node_hostnames"`
+	RequestsPerNode  map[string]int               `json:"requests_per_node"`
+	ProtocolsPerNode map[string]map[string]int    `json:"protocols_per_node"`
+	Latencies        map[string]*LatencyHistogram `json:"-"` // Exclude from JSON output
+	Retries          *RetryStats                  `json:"retries,omitempty"`
+	Interrupted      bool                         `json:"interrupted"`
+
+	// NodeLabels holds the labels the nodes sent in the X-Alcatraz-Node header
+	NodeLabels map[string]map[string]string `json:"node_labels,omitempty"`
+	// GroupBy is the label RequestsPerGroup is grouped by
+	GroupBy          string         `json:"group_by,omitempty"`
+	RequestsPerGroup map[string]int `json:"requests_per_group,omitempty"`
+}
+
+// UnlabeledGroup collects the requests of nodes without the grouping label
+const UnlabeledGroup = "(none)"
+
+// NewLoadBalancerStats creates empty statistics
+func NewLoadBalancerStats() *LoadBalancerStats {
+	return &LoadBalancerStats{
+		RequestsPerNode:  make(map[string]int),
+		ProtocolsPerNode: make(map[string]map[string]int),
+		Latencies:        make(map[string]*LatencyHistogram),
+	}
+}
+
+// Record adds the outcome of a single request served by hostname over
+// the proto HTTP version to the statistics
+func (s *LoadBalancerStats) Record(hostname, proto string, latency int64, err error) {
+	s.TotalRequests++
+
+	if err != nil {
+		s.FailedRequests++
+		return
+	}
+
+	s.SuccessfulReqs++
+	s.RequestsPerNode[hostname]++
+
+	if s.ProtocolsPerNode[hostname] == nil {
+		s.ProtocolsPerNode[hostname] = make(map[string]int)
+	}
+	s.ProtocolsPerNode[hostname][proto]++
+
+	if s.Latencies[hostname] == nil {
+		s.Latencies[hostname] = NewLatencyHistogram()
+	}
+	s.Latencies[hostname].Record(latency)
+}
+
+// RecordLabels sets the labels of the node
+func (s *LoadBalancerStats) RecordLabels(hostname string, labels map[string]string) {
+	if s.NodeLabels == nil {
+		s.NodeLabels = make(map[string]map[string]string)
+	}
+	s.NodeLabels[hostname] = labels
+}
+
+// RecordRetry adds the attempts made for a single request
+func (s *LoadBalancerStats) RecordRetry(outcome RetryOutcome, hostname string, maxAttempts int, err error) {
+	if s.Retries == nil {
+		s.Retries = newRetryStats()
+	}
+	s.Retries.record(outcome, hostname, maxAttempts, err)
+}
+
+// Merge adds the counters and latencies collected in other to the statistics
+func (s *LoadBalancerStats) Merge(other *LoadBalancerStats) {
+	s.TotalRequests += other.TotalRequests
+	s.SuccessfulReqs += other.SuccessfulReqs
+	s.FailedRequests += other.FailedRequests
+
+	for hostname, count := range other.RequestsPerNode {
+		s.RequestsPerNode[hostname] += count
+	}
+
+	for hostname, protocols := range other.ProtocolsPerNode {
+		if s.ProtocolsPerNode[hostname] == nil {
+			s.ProtocolsPerNode[hostname] = make(map[string]int)
+		}
+		for proto, count := range protocols {
+			s.ProtocolsPerNode[hostname][proto] += count
+		}
+	}
+
+	for hostname, histogram := range other.Latencies {
+		if s.Latencies[hostname] == nil {
+			s.Latencies[hostname] = NewLatencyHistogram()
+		}
+		s.Latencies[hostname].Merge(histogram)
+	}
+
+	if other.Retries != nil {
+		if s.Retries == nil {
+			s.Retries = newRetryStats()
+		}
+		s.Retries.merge(other.Retries)
+	}
+
+	for hostname, labels := range other.NodeLabels {
+		s.RecordLabels(hostname, labels)
+	}
+}
+
+// Group counts the successful requests per value of the node label key,
+// nodes without the label are counted as UnlabeledGroup
+func (s *LoadBalancerStats) Group(key string) {
+	s.GroupBy = key
+	s.RequestsPerGroup = make(map[string]int)
+	for hostname, count := range s.RequestsPerNode {
+		group, ok := s.NodeLabels[hostname][key]
+		if !ok {
+			group = UnlabeledGroup
+		}
+		s.RequestsPerGroup[group] += count
+	}
+}
+
+// OverallLatency merges the latencies of all nodes into a single histogram
+func (s *LoadBalancerStats) OverallLatency() *LatencyHistogram {
+	overall := NewLatencyHistogram()
+	for _, histogram := range s.Latencies {
+		overall.Merge(histogram)
+	}
+	return overall
+}
+
+// Summary returns the statistics without the detailed response times
+func (s *LoadBalancerStats) Summary() *LoadBalancerSummary {
+	return &LoadBalancerSummary{
+		AvailableNodes:   s.AvailableNodes,
+		TotalRequests:    s.TotalRequests,
+		SuccessfulReqs:   s.SuccessfulReqs,
+		FailedRequests:   s.FailedRequests,
+		AverageRespTime:  s.AverageRespTime,
+		NodeHostnames:    s.NodeHostnames,
+		RequestsPerNode:  s.RequestsPerNode,
+		ProtocolsPerNode: s.ProtocolsPerNode,
+		Retries:          s.Retries,
+		Interrupted:      s.Interrupted,
+		NodeLabels:       s.NodeLabels,
+		GroupBy:          s.GroupBy,
+		RequestsPerGroup: s.RequestsPerGroup,
+	}
+}
+
+// LoadBalancerSummary holds summary statistics for JSON output (without detailed response times)
+type LoadBalancerSummary struct {
+	AvailableNodes   int                          `json:"available_nodes"`
+	TotalRequests    int                          `json:"total_requests"`
+	SuccessfulReqs   int                          `json:"successful_requests"`
+	FailedRequests   int                          `json:"failed_requests"`
+	AverageRespTime  int64                        `json:"average_response_time_ms"`
+	NodeHostnames    []string                     `json:"node_hostnames"`
+	RequestsPerNode  map[string]int               `json:"requests_per_node"`
+	ProtocolsPerNode map[string]map[string]int    `json:"protocols_per_node"`
+	Retries          *RetryStats                  `json:"retries,omitempty"`
+	Interrupted      bool                         `json:"interrupted"`
+	NodeLabels       map[string]map[string]string `json:"node_labels,omitempty"`
+	GroupBy          string                       `json:"group_by,omitempty"`
+	RequestsPerGroup map[string]int               `json:"requests_per_group,omitempty"`
+}
+
+// Finalize derives the node list, node count and average response time
+// from the recorded requests
+func (s *LoadBalancerStats) Finalize() {
+	// Extract unique hostnames and sort them
+	hostnameSet := make(map[string]bool)
+	for hostname := range s.RequestsPerNode {
+		hostnameSet[hostname] = true
+	}
+
+	s.NodeHostnames = make([]string, 0, len(hostnameSet))
+	for hostname := range hostnameSet {
+		s.NodeHostnames = append(s.NodeHostnames, hostname)
+	}
+	sort.Strings(s.NodeHostnames)
+
+	s.AvailableNodes = len(s.NodeHostnames)
+
+	// Calculate average response time
+	s.AverageRespTime = s.OverallLatency().Mean()
+}
+
+// WriteStats writes the statistics in a human readable form
+func WriteStats(w io.Writer, stats *LoadBalancerStats) {
+	if stats.Interrupted {
+		fmt.Fprintln(w, "Run interrupted: partial results")
+	}
+	fmt.Fprintf(w, "Total Requests: %d\n", stats.TotalRequests)
+	fmt.Fprintf(w, "Successful Requests: %d\n", stats.SuccessfulReqs)
+	fmt.Fprintf(w, "Failed Requests: %d\n", stats.FailedRequests)
+	fmt.Fprintf(w, "Available Nodes: %d\n", stats.AvailableNodes)
+	fmt.Fprintf(w, "Average Response Time: %d ms\n\n", stats.AverageRespTime)
+
+	fmt.Fprintln(w, "=== Node Hostnames ===")
+	for i, hostname := range stats.NodeHostnames {
+		fmt.Fprintf(w, "%d. %s\n", i+1, hostname)
+	}
+
+	fmt.Fprintln(w, "\n=== Requests Per Node ===")
+	for _, hostname := range stats.NodeHostnames {
+		count := stats.RequestsPerNode[hostname]
+		percentage := float64(count) / float64(stats.SuccessfulReqs) * 100
+		fmt.Fprintf(w, "%-20s: %4d requests (%.1f%%)\n", hostname, count, percentage)
+	}
+
+	if stats.GroupBy != "" {
+		groups := make([]string, 0, len(stats.RequestsPerGroup))
+		for group := range stats.RequestsPerGroup {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+
+		fmt.Fprintf(w, "\n=== Requests Per %s ===\n", stats.GroupBy)
+		for _, group := range groups {
+			count := stats.RequestsPerGroup[group]
+			percentage := float64(count) / float64(stats.SuccessfulReqs) * 100
+			fmt.Fprintf(w, "%-20s: %4d requests (%.1f%%)\n", group, count, percentage)
+		}
+	}
+
+	if len(stats.ProtocolsPerNode) > 0 {
+		fmt.Fprintln(w, "\n=== Protocols Per Node ===")
+		for _, hostname := range stats.NodeHostnames {
+			protocols := stats.ProtocolsPerNode[hostname]
+			names := make([]string, 0, len(protocols))
+			for proto := range protocols {
+				names = append(names, proto)
+			}
+			sort.Strings(names)
+
+			parts := make([]string, 0, len(names))
+			for _, proto := range names {
+				parts = append(parts, fmt.Sprintf("%s=%d", proto, protocols[proto]))
+			}
+			fmt.Fprintf(w, "%-20s: %s\n", hostname, strings.Join(parts, ", "))
+		}
+	}
+
+	fmt.Fprintln(w, "\n=== Response Time Statistics (ms) ===")
+	for _, hostname := range stats.NodeHostnames {
+		histogram := stats.Latencies[hostname]
+		if histogram == nil || histogram.Count == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%-20s: avg=%3dms, min=%3dms, max=%3dms, p50=%3dms, p99=%3dms, count=%d\n",
+			hostname, histogram.Mean(), histogram.Min, histogram.Max,
+			histogram.Quantile(50), histogram.Quantile(99), histogram.Count)
+	}
+
+	if stats.Retries != nil {
+		writeRetries(w, stats.Retries)
+	}
+}
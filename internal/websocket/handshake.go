@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HandshakeError is returned by Upgrade when the request is not a valid
+// opening handshake, nothing has been written to the response yet
+type HandshakeError struct {
+	// Status to respond with, 426 when the client has to switch protocols
+	Status int
+	Detail string
+}
+
+func (e *HandshakeError) Error() string {
+	return e.Detail
+}
+
+// Upgrade completes the opening handshake of r and takes over the connection
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Detail: "the WebSocket handshake must use GET"}
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Detail: "the request must upgrade the connection to websocket"}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Detail: "only WebSocket version 13 is supported"}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Detail: "Sec-WebSocket-Key must be 16 bytes in base64"}
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over the connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	for name, values := range w.Header() {
+		for _, value := range values {
+			response += name + ": " + value + "\r\n"
+		}
+	}
+	response += "\r\n"
+
+	if _, err := io.WriteString(netConn, response); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake response: %w", err)
+	}
+	// The server may have cleared the deadline of the request already
+	_ = netConn.SetDeadline(time.Time{})
+
+	return newConn(netConn, brw.Reader, false), nil
+}
+
+// Dial opens a WebSocket connection to url (ws, wss, http or https) through
+// the transport of client, which has to speak HTTP/1.1. The handshake is
+// bounded by timeout, the connection lives until it is closed or ctx ends.
+func Dial(ctx context.Context, client *http.Client, url string, header http.Header, timeout time.Duration) (*Conn, *http.Response, error) {
+	switch {
+	case strings.HasPrefix(url, "ws://"):
+		url = "http://" + strings.TrimPrefix(url, "ws://")
+	case strings.HasPrefix(url, "wss://"):
+		url = "https://" + strings.TrimPrefix(url, "wss://")
+	}
+
+	// Cancelling the request context closes the upgraded connection, so
+	// the timeout only applies until the response arrived
+	ctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(timeout, cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	// The client timeout would also cover the lifetime of the connection
+	plain := &http.Client{Transport: client.Transport, Jar: client.Jar, CheckRedirect: client.CheckRedirect}
+	resp, err := plain.Do(req)
+	if !timer.Stop() || err != nil {
+		cancel()
+		if err == nil {
+			_ = resp.Body.Close()
+			err = errors.New("handshake timed out")
+		}
+		return nil, resp, fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, resp, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, resp, errors.New("invalid Sec-WebSocket-Accept in the handshake response")
+	}
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		cancel()
+		return nil, resp, errors.New("the transport does not support upgraded connections")
+	}
+	return newConn(&cancelOnClose{ReadWriteCloser: rwc, cancel: cancel}, nil, true), resp, nil
+}
+
+// cancelOnClose releases the request context with the connection
+type cancelOnClose struct {
+	io.ReadWriteCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadWriteCloser.Close()
+	c.cancel()
+	return err
+}
+
+// headerContains reports whether the comma separated header contains token,
+// ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
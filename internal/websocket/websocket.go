@@ -0,0 +1,351 @@
+// Package websocket implements the parts of the WebSocket protocol (RFC 6455)
+// the node and the sender need to test load balancers with upgraded
+// connections: the opening handshake, framing, fragmentation, ping, pong
+// and the closing handshake. Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // mandated by RFC 6455 for Sec-WebSocket-Accept
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Opcodes of the frames
+const (
+	continuationFrame = 0x0
+	TextMessage       = 0x1
+	BinaryMessage     = 0x2
+	CloseMessage      = 0x8
+	PingMessage       = 0x9
+	PongMessage       = 0xA
+)
+
+// Status codes of close frames
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+)
+
+// MaxMessageSize is the largest message a connection reads, larger ones
+// close the connection
+const MaxMessageSize = 64 << 10
+
+// maxControlPayload is the largest payload of control frames
+const maxControlPayload = 125
+
+// acceptGUID is appended to the key of the handshake, see RFC 6455 1.3
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// CloseError is returned by ReadMessage when the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed: %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed: %d (%s)", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection, reads must not be concurrent while writes
+// may happen from any goroutine
+type Conn struct {
+	rwc io.ReadWriteCloser
+	br  *bufio.Reader
+	// client connections mask their frames and expect unmasked ones
+	client bool
+
+	writeMu sync.Mutex
+	closed  bool
+
+	// OnPong is called with the payload of every pong, pings are answered
+	// by ReadMessage itself
+	OnPong func(payload []byte)
+
+	// IdleTimeout is how long ReadMessage waits for the next frame of any
+	// kind, the read deadline moves forward before every frame until the
+	// closing handshake starts. 0 waits forever. Like SetReadDeadline it is
+	// only supported by connections accepted by Upgrade.
+	IdleTimeout time.Duration
+}
+
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(rwc)
+	}
+	return &Conn{rwc: rwc, br: br, client: client}
+}
+
+// ReadMessage returns the next text or binary message, control frames are
+// handled on the way: pings are answered, pongs are passed to OnPong and a
+// close frame is answered and returned as *CloseError
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var opcode int
+	var message []byte
+
+	for {
+		if err := c.extendReadDeadline(); err != nil {
+			return 0, nil, err
+		}
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case PingMessage:
+			if err := c.pong(payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.OnPong != nil {
+				c.OnPong(payload)
+			}
+			continue
+		case CloseMessage:
+			closeErr := &CloseError{Code: CloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			// Answer with the same code unless we started the closing handshake,
+			// 1005 only reports a missing code and must not be sent
+			code := closeErr.Code
+			if code == CloseNoStatus {
+				code = CloseNormal
+			}
+			_ = c.WriteClose(code, "")
+			return 0, nil, closeErr
+		case TextMessage, BinaryMessage:
+			if message != nil {
+				return 0, nil, c.fail(CloseProtocolError, "new message before the previous one ended")
+			}
+			opcode = op
+			message = []byte{}
+		case continuationFrame:
+			if message == nil {
+				return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", op))
+		}
+
+		if len(message)+len(payload) > MaxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, fmt.Sprintf("message larger than %d bytes", MaxMessageSize))
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if opcode == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+			}
+			return opcode, message, nil
+		}
+	}
+}
+
+// extendReadDeadline gives the next frame IdleTimeout to arrive, once the
+// connection is closing the deadline of the closing handshake is kept
+func (c *Conn) extendReadDeadline() error {
+	if c.IdleTimeout <= 0 {
+		return nil
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	return c.SetReadDeadline(time.Now().Add(c.IdleTimeout))
+}
+
+// Closing reports whether a close frame was sent, after it only the close
+// frame of the peer is expected
+func (c *Conn) Closing() bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.closed
+}
+
+// readFrame reads a single frame and unmasks its payload
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits are set without an extension")
+	}
+	// Clients must mask their frames and servers must not, RFC 6455 5.1
+	if masked == c.client {
+		return false, 0, nil, c.fail(CloseProtocolError, "frame masking is wrong for the direction")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= CloseMessage && (!fin || length > maxControlPayload) {
+		return false, 0, nil, c.fail(CloseProtocolError, "control frames must not be fragmented or larger than 125 bytes")
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, c.fail(CloseMessageTooBig, fmt.Sprintf("message larger than %d bytes", MaxMessageSize))
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends the payload as a single frame of the given opcode
+func (c *Conn) WriteMessage(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(opcode, payload)
+}
+
+// pong answers a ping, pings arriving while the connection closes are ignored
+func (c *Conn) pong(payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	return c.writeFrame(PongMessage, payload)
+}
+
+// WriteClose starts or completes the closing handshake, the connection
+// still has to be closed with Close
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.writeFrame(CloseMessage, payload)
+}
+
+// writeFrame sends a final frame, the caller holds writeMu
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	if c.closed && opcode != CloseMessage {
+		return errors.New("websocket is closing")
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|byte(opcode))
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	if _, err := c.rwc.Write(frame); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+// fail closes the connection after a protocol violation of the peer
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteClose(code, reason)
+	_ = c.rwc.Close()
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// SetReadDeadline limits how long reads block, it is only supported by
+// connections accepted by Upgrade
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	conn, ok := c.rwc.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return errors.New("read deadlines are not supported by the connection")
+	}
+	return conn.SetReadDeadline(t)
+}
+
+// Close closes the underlying connection without a closing handshake
+func (c *Conn) Close() error {
+	return c.rwc.Close()
+}
+
+// acceptKey computes Sec-WebSocket-Accept for the key of the client
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID)) //nolint:gosec // mandated by RFC 6455
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer echoes every message until the client closes the connection
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			var handshakeErr *HandshakeError
+			if errors.As(err, &handshakeErr) {
+				http.Error(w, handshakeErr.Detail, handshakeErr.Status)
+			}
+			return
+		}
+		defer conn.Close()
+
+		for {
+			opcode, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(opcode, message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example of RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %q, want s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+}
+
+func TestUpgrade_InvalidHandshake(t *testing.T) {
+	server := echoServer(t)
+
+	valid := map[string]string{
+		"Connection":            "keep-alive, Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{name: "wrong method", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no upgrade", header: "Upgrade", value: "", wantStatus: http.StatusUpgradeRequired},
+		{name: "no connection upgrade", header: "Connection", value: "keep-alive", wantStatus: http.StatusUpgradeRequired},
+		{name: "old version", header: "Sec-WebSocket-Version", value: "8", wantStatus: http.StatusUpgradeRequired},
+		{name: "short key", header: "Sec-WebSocket-Key", value: "c2hvcnQ=", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range valid {
+				req.Header.Set(name, value)
+			}
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestConn_RoundTrip(t *testing.T) {
+	server := echoServer(t)
+
+	conn, resp, err := Dial(context.Background(), http.DefaultClient, "ws"+strings.TrimPrefix(server.URL, "http"), nil, time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	var pongs []string
+	conn.OnPong = func(payload []byte) {
+		pongs = append(pongs, string(payload))
+	}
+
+	messages := []struct {
+		name    string
+		opcode  int
+		payload string
+	}{
+		{name: "text", opcode: TextMessage, payload: "hello"},
+		{name: "binary", opcode: BinaryMessage, payload: "\x00\x01\x02"},
+		{name: "16 bit length", opcode: TextMessage, payload: strings.Repeat("a", 1000)},
+		{name: "64 bit length", opcode: TextMessage, payload: strings.Repeat("b", 0x10000)},
+	}
+
+	for _, m := range messages {
+		t.Run(m.name, func(t *testing.T) {
+			if err := conn.WriteMessage(PingMessage, []byte(m.name)); err != nil {
+				t.Fatalf("WriteMessage(ping) error = %v", err)
+			}
+			if err := conn.WriteMessage(m.opcode, []byte(m.payload)); err != nil {
+				t.Fatalf("WriteMessage() error = %v", err)
+			}
+
+			opcode, message, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if opcode != m.opcode || string(message) != m.payload {
+				t.Errorf("ReadMessage() = %d, %d bytes, want %d, %d bytes", opcode, len(message), m.opcode, len(m.payload))
+			}
+			if len(pongs) == 0 || pongs[len(pongs)-1] != m.name {
+				t.Errorf("pongs = %v, want the last one to be %q", pongs, m.name)
+			}
+		})
+	}
+
+	// The server answers the close frame with its own
+	if err := conn.WriteClose(CloseNormal, "bye"); err != nil {
+		t.Fatalf("WriteClose() error = %v", err)
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("ReadMessage() after close error = %v, want close %d", err, CloseNormal)
+	}
+}
+
+func TestConn_TooBig(t *testing.T) {
+	server := echoServer(t)
+
+	conn, _, err := Dial(context.Background(), http.DefaultClient, server.URL, nil, time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(BinaryMessage, make([]byte, MaxMessageSize+1)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, CloseMessageTooBig)
+	}
+}
+
+func TestDial_NotWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, _, err := Dial(context.Background(), http.DefaultClient, server.URL, nil, time.Second); err == nil {
+		t.Error("Dial() error = nil, want an error for a plain HTTP endpoint")
+	}
+}